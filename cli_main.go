@@ -3,39 +3,153 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 )
 
 func main() {
 	filePath := flag.String("input", "", "Path to the .dem file")
-	outputPath := flag.String("output", "", "Path to the output .json file (optional)")
+	outputPath := flag.String("output", "", "Path to the output file (optional; required for -format csv and -series)")
+	format := flag.String("format", "json", "Output format: json, minified-json, csv, binary")
+	includePositions := flag.Bool("positions", true, "Include per-frame X/Y/Z for players, projectiles and grenades")
+	tickSkip := flag.Int("tick-skip", 4, "Process every Nth engine tick")
+	stream := flag.Bool("stream", false, "Write NDJSON (one line per frame/round/kill/grenade event) to -output, or stdout if unset")
+	series := flag.String("series", "", "Comma-separated .dem paths to parse as a single BO3/BO5 series (e.g. demo1.dem,demo2.dem,demo3.dem)")
+	seriesID := flag.String("series-id", "", "Opaque ID echoed back on the series output")
+	team1Name := flag.String("team1", "", "Team1 display name for -series")
+	team2Name := flag.String("team2", "", "Team2 display name for -series")
 	flag.Parse()
 
+	if *series != "" {
+		paths := strings.Split(*series, ",")
+		readers := make([]io.Reader, 0, len(paths))
+		for _, path := range paths {
+			f, err := os.Open(strings.TrimSpace(path))
+			if err != nil {
+				panic(err)
+			}
+			defer f.Close()
+			readers = append(readers, f)
+		}
+
+		seriesData, err := ParseSeries(readers, SeriesOptions{
+			SeriesID:         *seriesID,
+			Team1Name:        *team1Name,
+			Team2Name:        *team2Name,
+			IncludePositions: *includePositions,
+			TickSkip:         *tickSkip,
+		})
+		if err != nil {
+			panic(err)
+		}
+
+		data, err := json.Marshal(seriesData)
+		if err != nil {
+			panic(err)
+		}
+
+		if *outputPath == "" {
+			fmt.Println("Error: -series requires -output")
+			return
+		}
+		if err := os.WriteFile(*outputPath, data, 0644); err != nil {
+			panic(err)
+		}
+		fmt.Printf("Successfully wrote series data to %s\n", *outputPath)
+		return
+	}
+
 	if *filePath == "" {
 		fmt.Println("Error: Please provide a file path using -input")
 		return
 	}
 
+	if *stream {
+		f, err := os.Open(*filePath)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+
+		out := os.Stdout
+		if *outputPath != "" {
+			created, err := os.Create(*outputPath)
+			if err != nil {
+				panic(err)
+			}
+			defer created.Close()
+			out = created
+		}
+
+		err = ParseDemoStream(f, out, StreamOptions{
+			IncludePositions: *includePositions,
+			TickSkip:         *tickSkip,
+		})
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	opts := ExportOptions{
+		IncludePositions: *includePositions,
+		TickSkip:         *tickSkip,
+	}
+	switch *format {
+	case "json":
+		opts.Format = FormatJSON
+	case "minified-json":
+		opts.Format = FormatMinifiedJSON
+	case "csv":
+		opts.Format = FormatCSV
+		if *outputPath == "" {
+			fmt.Println("Error: -format csv requires -output (a .zip path)")
+			return
+		}
+	case "binary":
+		opts.Format = FormatBinary
+	default:
+		fmt.Printf("Error: unknown -format %q\n", *format)
+		return
+	}
+
 	f, err := os.Open(*filePath)
 	if err != nil {
 		panic(err)
 	}
 	defer f.Close()
 
-	jsonData, err := ParseDemo(f)
+	if opts.Format == FormatCSV {
+		out, err := os.Create(*outputPath)
+		if err != nil {
+			panic(err)
+		}
+		defer out.Close()
+		opts.CSVWriter = out
+
+		if _, err := ParseDemoWithOptions(f, opts); err != nil {
+			panic(err)
+		}
+		fmt.Printf("Successfully wrote data to %s\n", *outputPath)
+		return
+	}
+
+	data, err := ParseDemoWithOptions(f, opts)
 	if err != nil {
 		panic(err)
 	}
 
 	if *outputPath != "" {
-		err = os.WriteFile(*outputPath, jsonData, 0644)
+		err = os.WriteFile(*outputPath, data, 0644)
 		if err != nil {
 			panic(err)
 		}
 		fmt.Printf("Successfully wrote data to %s\n", *outputPath)
 	} else {
-		fmt.Println(string(jsonData))
+		fmt.Println(string(data))
 	}
 }
@@ -0,0 +1,235 @@
+// Package csv writes demo match data as a fixed set of CSV files
+// (rounds.csv, kills.csv, players.csv, frames.csv). Column order for each
+// file is a documented contract: downstream tooling is expected to bulk-load
+// these files positionally rather than re-inspecting headers, so columns may
+// only ever be appended, never reordered or removed.
+package csv
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// Column order is the contract referenced above.
+var (
+	RoundsHeader  = []string{"number", "tick", "ct_score", "t_score", "winning_team", "freeze_time_tick"}
+	KillsHeader   = []string{"tick", "killer_id", "victim_id", "assister_id", "is_headshot", "weapon"}
+	PlayersHeader = []string{"id", "name", "team", "roster_index", "kills", "deaths", "assists", "hs"}
+	FramesHeader  = []string{"tick", "player_id", "x", "y", "z", "hp", "armor", "is_alive"}
+)
+
+// Round mirrors the fields of RoundData that are meaningful as a CSV row.
+type Round struct {
+	Number         int
+	Tick           int
+	CTScore        int
+	TScore         int
+	WinningTeam    string
+	FreezeTimeTick int
+}
+
+// Kill mirrors KillEvent.
+type Kill struct {
+	Tick       int
+	KillerID   uint64
+	VictimID   uint64
+	AssisterID uint64
+	IsHeadshot bool
+	Weapon     string
+}
+
+// Player mirrors the identity/aggregate-stat portion of PlayerData. It is
+// written once per player, not once per frame.
+type Player struct {
+	ID          uint64
+	Name        string
+	Team        string
+	RosterIndex int
+	Kills       int
+	Deaths      int
+	Assists     int
+	HS          int
+}
+
+// Frame mirrors one player's row within a FrameData tick. X/Y/Z are written
+// as empty fields (the columns stay in place) when the exporter was built
+// with includePositions set to false.
+type Frame struct {
+	Tick     int
+	PlayerID uint64
+	X, Y, Z  float64
+	Hp       int
+	Armor    int
+	IsAlive  bool
+}
+
+// Exporter is implemented by every export backend. CSV is the first
+// implementation; adding a new format (e.g. a future Parquet exporter) is a
+// matter of implementing these methods.
+type Exporter interface {
+	WriteRound(Round) error
+	WriteKill(Kill) error
+	WritePlayer(Player) error
+	WriteFrame(Frame) error
+	Finalize() error
+}
+
+// CSVExporter writes the four CSV files directly to the io.Writers it was
+// constructed with. Rows are buffered by each csv.Writer and reach the
+// underlying io.Writer when that buffer fills or when Finalize is called.
+type CSVExporter struct {
+	rounds  *csv.Writer
+	kills   *csv.Writer
+	players *csv.Writer
+	frames  *csv.Writer
+
+	includePositions bool
+}
+
+// NewExporter returns an Exporter that writes each CSV file to its own
+// io.Writer. Headers are written immediately.
+func NewExporter(rounds, kills, players, frames io.Writer, includePositions bool) (*CSVExporter, error) {
+	e := &CSVExporter{
+		rounds:           csv.NewWriter(rounds),
+		kills:            csv.NewWriter(kills),
+		players:          csv.NewWriter(players),
+		frames:           csv.NewWriter(frames),
+		includePositions: includePositions,
+	}
+	for _, w := range []struct {
+		cw     *csv.Writer
+		header []string
+	}{
+		{e.rounds, RoundsHeader},
+		{e.kills, KillsHeader},
+		{e.players, PlayersHeader},
+		{e.frames, FramesHeader},
+	} {
+		if err := w.cw.Write(w.header); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+func (e *CSVExporter) WriteRound(r Round) error {
+	return e.rounds.Write([]string{
+		strconv.Itoa(r.Number),
+		strconv.Itoa(r.Tick),
+		strconv.Itoa(r.CTScore),
+		strconv.Itoa(r.TScore),
+		r.WinningTeam,
+		strconv.Itoa(r.FreezeTimeTick),
+	})
+}
+
+func (e *CSVExporter) WriteKill(k Kill) error {
+	assister := ""
+	if k.AssisterID != 0 {
+		assister = strconv.FormatUint(k.AssisterID, 10)
+	}
+	return e.kills.Write([]string{
+		strconv.Itoa(k.Tick),
+		strconv.FormatUint(k.KillerID, 10),
+		strconv.FormatUint(k.VictimID, 10),
+		assister,
+		strconv.FormatBool(k.IsHeadshot),
+		k.Weapon,
+	})
+}
+
+func (e *CSVExporter) WritePlayer(p Player) error {
+	return e.players.Write([]string{
+		strconv.FormatUint(p.ID, 10),
+		p.Name,
+		p.Team,
+		strconv.Itoa(p.RosterIndex),
+		strconv.Itoa(p.Kills),
+		strconv.Itoa(p.Deaths),
+		strconv.Itoa(p.Assists),
+		strconv.Itoa(p.HS),
+	})
+}
+
+func (e *CSVExporter) WriteFrame(f Frame) error {
+	x, y, z := "", "", ""
+	if e.includePositions {
+		x = strconv.FormatFloat(f.X, 'f', -1, 64)
+		y = strconv.FormatFloat(f.Y, 'f', -1, 64)
+		z = strconv.FormatFloat(f.Z, 'f', -1, 64)
+	}
+	return e.frames.Write([]string{
+		strconv.Itoa(f.Tick),
+		strconv.FormatUint(f.PlayerID, 10),
+		x, y, z,
+		strconv.Itoa(f.Hp),
+		strconv.Itoa(f.Armor),
+		strconv.FormatBool(f.IsAlive),
+	})
+}
+
+// Finalize flushes all four underlying csv.Writers.
+func (e *CSVExporter) Finalize() error {
+	for _, w := range []*csv.Writer{e.rounds, e.kills, e.players, e.frames} {
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ZipExporter buffers the same four CSV files in memory and writes them as
+// entries in a zip archive on Finalize, since the zip format requires each
+// entry to be written in full before the next one starts.
+type ZipExporter struct {
+	*CSVExporter
+	roundsBuf, killsBuf, playersBuf, framesBuf *bytes.Buffer
+	zw                                         *zip.Writer
+}
+
+// NewZipExporter returns an Exporter whose four CSV files are written as
+// rounds.csv, kills.csv, players.csv and frames.csv entries in a zip archive
+// streamed to w once Finalize is called.
+func NewZipExporter(w io.Writer, includePositions bool) (*ZipExporter, error) {
+	roundsBuf, killsBuf, playersBuf, framesBuf := &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}
+	inner, err := NewExporter(roundsBuf, killsBuf, playersBuf, framesBuf, includePositions)
+	if err != nil {
+		return nil, err
+	}
+	return &ZipExporter{
+		CSVExporter: inner,
+		roundsBuf:   roundsBuf,
+		killsBuf:    killsBuf,
+		playersBuf:  playersBuf,
+		framesBuf:   framesBuf,
+		zw:          zip.NewWriter(w),
+	}, nil
+}
+
+func (e *ZipExporter) Finalize() error {
+	if err := e.CSVExporter.Finalize(); err != nil {
+		return err
+	}
+	for _, f := range []struct {
+		name string
+		buf  *bytes.Buffer
+	}{
+		{"rounds.csv", e.roundsBuf},
+		{"kills.csv", e.killsBuf},
+		{"players.csv", e.playersBuf},
+		{"frames.csv", e.framesBuf},
+	} {
+		w, err := e.zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(f.buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return e.zw.Close()
+}
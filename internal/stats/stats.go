@@ -0,0 +1,388 @@
+// Package stats computes rich per-player match statistics (utility damage,
+// accuracy, trade/opening kills, clutches, KAST, MVPs) from the same event
+// stream the main parser already consumes. It is kept separate from the
+// parser's main loop so that loop stays readable: pipeline.go just forwards
+// each relevant event to a *Tracker and reads back a snapshot once parsing
+// finishes.
+package stats
+
+import "sort"
+
+// WeaponStat is a player's hit count and damage dealt with a single weapon.
+type WeaponStat struct {
+	Hits   int `json:"hits"`
+	Damage int `json:"damage"`
+}
+
+// ClutchRecord is one clutch situation a player faced: alone against Vs
+// opponents, with Won set once the round outcome is known.
+type ClutchRecord struct {
+	Vs  int  `json:"vs"`
+	Won bool `json:"won"`
+}
+
+// PlayerMatchStats is the rich per-player stat line for a single match.
+// PlayerData.Kills/Deaths/Assists/HS stay as-is for backward compatibility;
+// this is emitted alongside them as PlayerData.Stats.
+type PlayerMatchStats struct {
+	ShotsFired int                    `json:"shots_fired"`
+	ShotsHit   int                    `json:"shots_hit"`
+	WeaponHits map[string]*WeaponStat `json:"weapon_hits,omitempty"`
+
+	UtilityDamage int `json:"utility_damage"`
+
+	FlashesThrown   int `json:"flashes_thrown"`
+	EnemiesFlashed  int `json:"enemies_flashed"`
+	FlashDurationMs int `json:"flash_duration_ms"`
+
+	TradeKills int `json:"trade_kills"`
+
+	OpeningKills  int `json:"opening_kills"`
+	OpeningDeaths int `json:"opening_deaths"`
+
+	ClutchesAttempted int            `json:"clutches_attempted"`
+	ClutchesWon       int            `json:"clutches_won"`
+	Clutches          []ClutchRecord `json:"clutches,omitempty"`
+
+	BombPlants  int `json:"bomb_plants"`
+	BombDefuses int `json:"bomb_defuses"`
+
+	MVPs int `json:"mvps"`
+
+	RoundsPlayed int `json:"rounds_played"`
+	RoundsKAST   int `json:"rounds_kast"`
+}
+
+// weaponStat returns (creating if needed) the WeaponStat for weapon.
+func (s *PlayerMatchStats) weaponStat(weapon string) *WeaponStat {
+	if s.WeaponHits == nil {
+		s.WeaponHits = make(map[string]*WeaponStat)
+	}
+	if _, ok := s.WeaponHits[weapon]; !ok {
+		s.WeaponHits[weapon] = &WeaponStat{}
+	}
+	return s.WeaponHits[weapon]
+}
+
+// deathRecord is one kill this round, kept around just long enough to detect
+// a trade: if the killer recorded here is themselves killed within the trade
+// window, the original victim's death counts as "traded" for KAST.
+type deathRecord struct {
+	victimID uint64
+	killerID uint64
+	tick     int
+}
+
+// roundState is the rolling per-round context needed to derive trade
+// kills, opening duels, clutches and KAST. It is reset at the start of
+// every round.
+type roundState struct {
+	openingDuelDone bool
+	lastDeathTick   map[string]int // team -> tick of that team's most recent death this round
+	aliveCT         int
+	aliveT          int
+	alive           map[uint64]string // participants who haven't died yet this round -> their team, for KAST's Survive credit and clutch detection
+	damageByPlayer  map[uint64]int
+	killsByPlayer   map[uint64]int
+	recentDeaths    []deathRecord
+	kastCredit      map[uint64]bool
+	clutcherID      uint64 // set once a player is left alone against the other team
+	clutchTeam      string
+	clutchVs        int
+}
+
+// Tracker accumulates PlayerMatchStats across a single demo. Kills/Deaths/
+// Assists/HS are intentionally not duplicated here: callers keep computing
+// those themselves and read this type only for the additional stats.
+type Tracker struct {
+	players map[uint64]*PlayerMatchStats
+	round   roundState
+
+	// TradeWindowTicks is how soon after a teammate's death a kill counts
+	// as a trade. Defaults to 320 ticks (5s at 64 tick) via NewTracker.
+	TradeWindowTicks int
+}
+
+// NewTracker returns an empty Tracker ready to receive events.
+func NewTracker() *Tracker {
+	return &Tracker{
+		players:          make(map[uint64]*PlayerMatchStats),
+		TradeWindowTicks: 320,
+	}
+}
+
+func (t *Tracker) player(id uint64) *PlayerMatchStats {
+	if _, ok := t.players[id]; !ok {
+		t.players[id] = &PlayerMatchStats{}
+	}
+	return t.players[id]
+}
+
+// BeginRound resets the rolling per-round context. participants maps every
+// player alive at round start to their team ("CT" or "T"), seeding both the
+// alive-side counts used for clutch detection and the Survive credit tracked
+// in EndRound.
+func (t *Tracker) BeginRound(participants map[uint64]string) {
+	alive := make(map[uint64]string, len(participants))
+	var aliveCT, aliveT int
+	for id, team := range participants {
+		alive[id] = team
+		switch team {
+		case "CT":
+			aliveCT++
+		case "T":
+			aliveT++
+		}
+	}
+	t.round = roundState{
+		lastDeathTick:  make(map[string]int),
+		aliveCT:        aliveCT,
+		aliveT:         aliveT,
+		alive:          alive,
+		damageByPlayer: make(map[uint64]int),
+		killsByPlayer:  make(map[uint64]int),
+		kastCredit:     make(map[uint64]bool),
+	}
+	for id := range participants {
+		t.player(id).RoundsPlayed++
+	}
+}
+
+// RecordWeaponFire logs a shot taken, for accuracy tracking.
+func (t *Tracker) RecordWeaponFire(shooterID uint64) {
+	t.player(shooterID).ShotsFired++
+}
+
+// RecordHurt logs a hit: a weapon connecting with a victim for damage.
+// isUtility marks HE/molotov/incendiary damage, which rolls up into
+// UtilityDamage instead of the per-weapon hit table: it is excluded from
+// ShotsHit/WeaponHits since those are accuracy counters for
+// aimed weapon fire. Dealing damage is not itself a KAST criterion (Kill,
+// Assist, Survive, Traded), so this does not touch kastCredit.
+func (t *Tracker) RecordHurt(attackerID, victimID uint64, weapon string, damage int, isUtility bool) {
+	if attackerID == 0 || attackerID == victimID {
+		return
+	}
+	attacker := t.player(attackerID)
+	if !isUtility {
+		attacker.ShotsHit++
+		ws := attacker.weaponStat(weapon)
+		ws.Hits++
+		ws.Damage += damage
+	} else {
+		attacker.UtilityDamage += damage
+	}
+
+	t.round.damageByPlayer[attackerID] += damage
+}
+
+// RecordFlashThrown logs a flashbang thrown by throwerID.
+func (t *Tracker) RecordFlashThrown(throwerID uint64) {
+	t.player(throwerID).FlashesThrown++
+}
+
+// RecordFlashed logs throwerID blinding a victim on the opposing team for
+// durationMs. Flashing a teammate does not count.
+func (t *Tracker) RecordFlashed(throwerID uint64, sameTeam bool, durationMs int) {
+	if sameTeam {
+		return
+	}
+	p := t.player(throwerID)
+	p.EnemiesFlashed++
+	p.FlashDurationMs += durationMs
+}
+
+// RecordBombPlant logs a successful plant by playerID. Plants aren't one of
+// KAST's four credited events (Kill, Assist, Survive, Traded), so this does
+// not touch kastCredit.
+func (t *Tracker) RecordBombPlant(playerID uint64) {
+	t.player(playerID).BombPlants++
+}
+
+// RecordBombDefuse logs a successful defuse by playerID. Like RecordBombPlant,
+// this does not grant KAST credit on its own.
+func (t *Tracker) RecordBombDefuse(playerID uint64) {
+	t.player(playerID).BombDefuses++
+}
+
+// RecordKill logs a kill at the given ingame tick. killerTeam/victimTeam are
+// "CT" or "T". It derives opening kills/deaths, trade kills, KAST credit and
+// clutch-situation detection from the rolling round state.
+func (t *Tracker) RecordKill(killerID, victimID, assisterID uint64, tick int, killerTeam, victimTeam string) {
+	if !t.round.openingDuelDone {
+		t.round.openingDuelDone = true
+		if killerID != 0 {
+			t.player(killerID).OpeningKills++
+		}
+		t.player(victimID).OpeningDeaths++
+	}
+
+	// A trade kill is a kill that avenges a teammate who died moments ago:
+	// killerID's own team suffered a death within the trade window, and
+	// this kill is on the team that caused it.
+	if killerID != 0 {
+		if lastDeath, ok := t.round.lastDeathTick[killerTeam]; ok && tick-lastDeath <= t.TradeWindowTicks {
+			t.player(killerID).TradeKills++
+		}
+		t.round.killsByPlayer[killerID]++
+		t.round.kastCredit[killerID] = true
+	}
+	if assisterID != 0 {
+		t.round.kastCredit[assisterID] = true
+	}
+
+	// KAST's "Traded" credit: if this kill's victim was themselves the
+	// killer in an earlier death this round, within the trade window, that
+	// earlier victim's death counts as avenged.
+	for _, d := range t.round.recentDeaths {
+		if d.killerID != 0 && d.killerID == victimID && tick-d.tick <= t.TradeWindowTicks {
+			t.round.kastCredit[d.victimID] = true
+		}
+	}
+	t.round.recentDeaths = append(t.round.recentDeaths, deathRecord{victimID: victimID, killerID: killerID, tick: tick})
+	delete(t.round.alive, victimID)
+
+	t.round.lastDeathTick[victimTeam] = tick
+	if victimTeam == "CT" {
+		t.round.aliveCT--
+	} else if victimTeam == "T" {
+		t.round.aliveT--
+	}
+
+	// A clutch attempt is recorded once per round, the moment this death
+	// drops the victim's team to exactly one alive player with at least one
+	// opponent still standing. That remaining player is the clutcher, and
+	// clutchVs is the number of opponents alive at that moment.
+	if t.round.clutcherID == 0 {
+		mine, theirs := t.round.aliveCT, t.round.aliveT
+		if victimTeam == "T" {
+			mine, theirs = t.round.aliveT, t.round.aliveCT
+		}
+		if mine == 1 && theirs >= 1 {
+			for id, team := range t.round.alive {
+				if team == victimTeam {
+					t.round.clutcherID = id
+					t.round.clutchTeam = victimTeam
+					t.round.clutchVs = theirs
+				}
+			}
+		}
+	}
+}
+
+// EndRound resolves the round's MVP (most round damage, kills breaking
+// ties, lowest SteamID64 breaking any remaining tie so the result is
+// reproducible across runs of the same demo) and clutch outcome, finalizes
+// KAST credit (granting every player who survived the round, in addition to
+// whatever Kill/Assist/Traded credit RecordKill already set), and returns
+// the MVP's SteamID64 (0 if the round had no damage at all, e.g. it ended
+// in a knife-round-style draw).
+func (t *Tracker) EndRound(winningTeam string) uint64 {
+	for id := range t.round.alive {
+		t.round.kastCredit[id] = true
+	}
+
+	ids := make([]uint64, 0, len(t.round.damageByPlayer))
+	for id := range t.round.damageByPlayer {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var mvp uint64
+	bestDamage, bestKills := -1, -1
+	for _, id := range ids {
+		dmg := t.round.damageByPlayer[id]
+		kills := t.round.killsByPlayer[id]
+		if dmg > bestDamage || (dmg == bestDamage && kills > bestKills) {
+			bestDamage, bestKills = dmg, kills
+			mvp = id
+		}
+	}
+	if mvp != 0 {
+		t.player(mvp).MVPs++
+	}
+
+	if t.round.clutcherID != 0 {
+		p := t.player(t.round.clutcherID)
+		p.ClutchesAttempted++
+		won := t.round.clutchTeam == winningTeam
+		if won {
+			p.ClutchesWon++
+		}
+		p.Clutches = append(p.Clutches, ClutchRecord{Vs: t.round.clutchVs, Won: won})
+	}
+
+	for id, credited := range t.round.kastCredit {
+		if credited {
+			t.player(id).RoundsKAST++
+		}
+	}
+
+	return mvp
+}
+
+// MergeInto adds src's counters into dst in place, for aggregating a
+// player's stats across multiple maps of a series. dst must not be nil.
+func MergeInto(dst, src *PlayerMatchStats) {
+	dst.ShotsFired += src.ShotsFired
+	dst.ShotsHit += src.ShotsHit
+	for weapon, ws := range src.WeaponHits {
+		dwh := dst.weaponStat(weapon)
+		dwh.Hits += ws.Hits
+		dwh.Damage += ws.Damage
+	}
+	dst.UtilityDamage += src.UtilityDamage
+	dst.FlashesThrown += src.FlashesThrown
+	dst.EnemiesFlashed += src.EnemiesFlashed
+	dst.FlashDurationMs += src.FlashDurationMs
+	dst.TradeKills += src.TradeKills
+	dst.OpeningKills += src.OpeningKills
+	dst.OpeningDeaths += src.OpeningDeaths
+	dst.ClutchesAttempted += src.ClutchesAttempted
+	dst.ClutchesWon += src.ClutchesWon
+	dst.Clutches = append(dst.Clutches, src.Clutches...)
+	dst.BombPlants += src.BombPlants
+	dst.BombDefuses += src.BombDefuses
+	dst.MVPs += src.MVPs
+	dst.RoundsPlayed += src.RoundsPlayed
+	dst.RoundsKAST += src.RoundsKAST
+}
+
+// copyPlayerStats deep-copies a PlayerMatchStats so the result is unaffected
+// by later mutations to s (used when callers snapshot mid-match, e.g. once
+// per sampled frame, rather than only at the very end).
+func copyPlayerStats(s *PlayerMatchStats) *PlayerMatchStats {
+	cp := *s
+	if s.WeaponHits != nil {
+		cp.WeaponHits = make(map[string]*WeaponStat, len(s.WeaponHits))
+		for weapon, ws := range s.WeaponHits {
+			wsCopy := *ws
+			cp.WeaponHits[weapon] = &wsCopy
+		}
+	}
+	if s.Clutches != nil {
+		cp.Clutches = append([]ClutchRecord(nil), s.Clutches...)
+	}
+	return &cp
+}
+
+// SnapshotPlayer returns a point-in-time deep copy of one player's stats, or
+// nil if that SteamID64 hasn't been seen yet.
+func (t *Tracker) SnapshotPlayer(id uint64) *PlayerMatchStats {
+	s, ok := t.players[id]
+	if !ok {
+		return nil
+	}
+	return copyPlayerStats(s)
+}
+
+// Snapshot returns the accumulated stats for every player seen so far,
+// keyed by SteamID64. The returned map is safe for the caller to keep;
+// Tracker never mutates previously returned entries in place.
+func (t *Tracker) Snapshot() map[uint64]*PlayerMatchStats {
+	out := make(map[uint64]*PlayerMatchStats, len(t.players))
+	for id, s := range t.players {
+		out[id] = copyPlayerStats(s)
+	}
+	return out
+}
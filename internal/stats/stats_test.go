@@ -0,0 +1,170 @@
+package stats
+
+import "testing"
+
+const (
+	ctTeam = "CT"
+	tTeam  = "T"
+)
+
+func newRoundParticipants(ctIDs, tIDs []uint64) map[uint64]string {
+	participants := make(map[uint64]string, len(ctIDs)+len(tIDs))
+	for _, id := range ctIDs {
+		participants[id] = ctTeam
+	}
+	for _, id := range tIDs {
+		participants[id] = tTeam
+	}
+	return participants
+}
+
+// TestKAST_KillAssistSurvive checks the K/A/S of KAST: the killer, the
+// assister and any player who isn't killed this round all get credit, while
+// a player who dies without being traded does not.
+func TestKAST_KillAssistSurvive(t *testing.T) {
+	tr := NewTracker()
+	tr.BeginRound(newRoundParticipants([]uint64{1, 2}, []uint64{3, 4}))
+
+	tr.RecordKill(1 /*killer*/, 3 /*victim*/, 2 /*assister*/, 100, ctTeam, tTeam)
+	tr.EndRound(ctTeam)
+
+	for _, id := range []uint64{1, 2, 4} {
+		if got := tr.player(id).RoundsKAST; got != 1 {
+			t.Errorf("player %d: RoundsKAST = %d, want 1", id, got)
+		}
+	}
+	if got := tr.player(3).RoundsKAST; got != 0 {
+		t.Errorf("victim 3: RoundsKAST = %d, want 0 (died, not traded)", got)
+	}
+}
+
+// TestKAST_Traded checks that a player whose killer is killed shortly after
+// (within the trade window) gets Traded credit, even though they died.
+func TestKAST_Traded(t *testing.T) {
+	tr := NewTracker()
+	tr.BeginRound(newRoundParticipants([]uint64{1, 2}, []uint64{3, 4}))
+
+	tr.RecordKill(3 /*killer*/, 1 /*victim*/, 0, 100, tTeam, ctTeam)
+	tr.RecordKill(2 /*killer*/, 3 /*victim*/, 0, 110, ctTeam, tTeam)
+	tr.EndRound(ctTeam)
+
+	if got := tr.player(1).RoundsKAST; got != 1 {
+		t.Errorf("traded victim 1: RoundsKAST = %d, want 1", got)
+	}
+}
+
+// TestKAST_DamageAloneDoesNotCredit guards against the metric being
+// inflated by mere damage dealt, or by non-standard events like bomb
+// plants/defuses.
+func TestKAST_DamageAloneDoesNotCredit(t *testing.T) {
+	tr := NewTracker()
+	tr.BeginRound(newRoundParticipants([]uint64{1}, []uint64{2}))
+
+	tr.RecordHurt(1, 2, "ak47", 40, false)
+	tr.RecordBombPlant(1)
+	tr.RecordBombDefuse(2)
+	// Round ends without anyone dying and without player 1 or 2 being part
+	// of the alive set passed to BeginRound being reduced, so both survive
+	// and both get credit - but for Survive, not for damage/plant/defuse.
+	tr.EndRound(tTeam)
+
+	if got := tr.player(1).RoundsKAST; got != 1 {
+		t.Errorf("player 1: RoundsKAST = %d, want 1 (from Survive)", got)
+	}
+	if got := tr.player(2).RoundsKAST; got != 1 {
+		t.Errorf("player 2: RoundsKAST = %d, want 1 (from Survive)", got)
+	}
+}
+
+// TestRecordHurt_UtilityDoesNotCountAsShotHit ensures HE/molotov damage
+// rolls up into UtilityDamage only, not the aimed-weapon accuracy counters.
+func TestRecordHurt_UtilityDoesNotCountAsShotHit(t *testing.T) {
+	tr := NewTracker()
+	tr.BeginRound(newRoundParticipants([]uint64{1}, []uint64{2}))
+
+	tr.RecordHurt(1, 2, "hegrenade", 20, true)
+	tr.RecordHurt(1, 2, "ak47", 30, false)
+
+	p := tr.player(1)
+	if p.ShotsHit != 1 {
+		t.Errorf("ShotsHit = %d, want 1 (only the ak47 hit)", p.ShotsHit)
+	}
+	if p.UtilityDamage != 20 {
+		t.Errorf("UtilityDamage = %d, want 20", p.UtilityDamage)
+	}
+	if ws, ok := p.WeaponHits["hegrenade"]; ok {
+		t.Errorf("WeaponHits[hegrenade] = %+v, want no entry", ws)
+	}
+}
+
+// TestRecordKill_TradeWindow checks that an avenging kill outside the trade
+// window doesn't count as a trade kill.
+func TestRecordKill_TradeWindow(t *testing.T) {
+	tr := NewTracker()
+	tr.TradeWindowTicks = 100
+	tr.BeginRound(newRoundParticipants([]uint64{1, 2}, []uint64{3, 4}))
+
+	tr.RecordKill(3, 1, 0, 100, tTeam, ctTeam)
+	tr.RecordKill(2, 3, 0, 300, ctTeam, tTeam) // 200 ticks later, outside the window
+	tr.EndRound(ctTeam)
+
+	if got := tr.player(2).TradeKills; got != 0 {
+		t.Errorf("TradeKills = %d, want 0 (outside trade window)", got)
+	}
+	if got := tr.player(1).RoundsKAST; got != 0 {
+		t.Errorf("player 1: RoundsKAST = %d, want 0 (not traded, outside window)", got)
+	}
+}
+
+// TestEndRound_MVPTiebreak checks the deterministic MVP tiebreak: most
+// damage wins, kills break a damage tie, and the lowest SteamID64 breaks
+// any remaining tie.
+func TestEndRound_MVPTiebreak(t *testing.T) {
+	tr := NewTracker()
+	tr.BeginRound(newRoundParticipants([]uint64{10, 20}, []uint64{30}))
+
+	tr.RecordHurt(10, 30, "ak47", 50, false)
+	tr.RecordHurt(20, 30, "m4a1", 50, false)
+	tr.RecordKill(20, 30, 0, 100, ctTeam, tTeam)
+
+	if mvp := tr.EndRound(ctTeam); mvp != 20 {
+		t.Errorf("MVP = %d, want 20 (same damage, more kills)", mvp)
+	}
+}
+
+func TestEndRound_MVPTiebreak_LowestSteamID(t *testing.T) {
+	tr := NewTracker()
+	tr.BeginRound(newRoundParticipants([]uint64{20, 10}, []uint64{30}))
+
+	tr.RecordHurt(20, 30, "ak47", 50, false)
+	tr.RecordHurt(10, 30, "m4a1", 50, false)
+
+	if mvp := tr.EndRound(tTeam); mvp != 10 {
+		t.Errorf("MVP = %d, want 10 (same damage, same kills, lowest SteamID64)", mvp)
+	}
+}
+
+// TestClutch checks that a clutch is recorded once a player's team drops to
+// one alive member against at least one opponent, and resolved won/lost by
+// the round's winning team.
+func TestClutch(t *testing.T) {
+	tr := NewTracker()
+	tr.BeginRound(newRoundParticipants([]uint64{1, 2}, []uint64{3, 4, 5}))
+
+	tr.RecordKill(3, 1, 0, 100, tTeam, ctTeam) // CT drops to 1 (player 2), T still has 3
+	tr.RecordKill(2, 3, 0, 110, ctTeam, tTeam)
+	tr.RecordKill(2, 4, 0, 120, ctTeam, tTeam)
+	tr.RecordKill(2, 5, 0, 130, ctTeam, tTeam)
+	tr.EndRound(ctTeam)
+
+	p := tr.player(2)
+	if p.ClutchesAttempted != 1 {
+		t.Fatalf("ClutchesAttempted = %d, want 1", p.ClutchesAttempted)
+	}
+	if p.ClutchesWon != 1 {
+		t.Errorf("ClutchesWon = %d, want 1", p.ClutchesWon)
+	}
+	if len(p.Clutches) != 1 || p.Clutches[0].Vs != 3 || !p.Clutches[0].Won {
+		t.Errorf("Clutches = %+v, want one won record with Vs = 3", p.Clutches)
+	}
+}
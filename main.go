@@ -4,14 +4,53 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"sort"
 
-	dem "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs"
-	common "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/common"
-	events "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/events"
-	msg "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+	exportcsv "github.com/yerevin/cs2-2d-demoviewer/internal/export/csv"
+	"github.com/yerevin/cs2-2d-demoviewer/internal/stats"
+	"github.com/yerevin/cs2-2d-demoviewer/pkg/binfmt"
 )
 
+// ExportFormat selects the output encoding produced by ParseDemoWithOptions.
+type ExportFormat int
+
+const (
+	FormatJSON ExportFormat = iota
+	FormatMinifiedJSON
+	FormatCSV
+	FormatBinary
+)
+
+// ExportOptions controls how ParseDemoWithOptions renders a parsed demo.
+type ExportOptions struct {
+	Format ExportFormat
+
+	// IncludePositions controls whether per-frame X/Y/Z is emitted for
+	// players, projectiles and grenades. Set to false for a much smaller
+	// "events-only" export.
+	IncludePositions bool
+
+	// TickSkip is the frame sampling interval (process every Nth engine
+	// tick). Defaults to 4 when left at zero.
+	TickSkip int
+
+	// CSVWriter receives the CSV/zip payload when Format is FormatCSV. It is
+	// required in that case; ParseDemoWithOptions returns an error if it is
+	// nil.
+	CSVWriter io.Writer
+}
+
+// DefaultExportOptions returns the options that reproduce today's ParseDemo
+// behavior: full JSON, positions included, tickSkip 4.
+func DefaultExportOptions() ExportOptions {
+	return ExportOptions{
+		Format:           FormatJSON,
+		IncludePositions: true,
+		TickSkip:         4,
+	}
+}
+
 // Data structures for JSON output
 type WeaponData struct {
 	Name  string `json:"name"`
@@ -42,6 +81,13 @@ type PlayerData struct {
 	IsFlashed    bool         `json:"is_flashed"`
 	FlashMs      int          `json:"flash_ms"`
 	RosterIndex  int          `json:"roster_index"` // 1-10, assigned at match start
+
+	// Stats holds the richer per-match stat line (utility damage, accuracy,
+	// clutches, KAST, ...) computed by internal/stats. It only makes sense
+	// as a running match total, not a per-frame value, so it is nil on every
+	// frame except the match's last one (ParseDemoStream instead reports it
+	// once, on streamMatchFooter).
+	Stats *stats.PlayerMatchStats `json:"stats,omitempty"`
 }
 
 type KillEvent struct {
@@ -75,8 +121,18 @@ type BombData struct {
 }
 
 type WeaponFire struct {
-	PlayerID uint64 `json:"player_id"`
-	Weapon   string `json:"weapon"`
+	PlayerID uint64  `json:"player_id"`
+	Weapon   string  `json:"weapon"`
+	Pitch    float32 `json:"pitch"`
+	Yaw      float32 `json:"yaw"`
+
+	// TracerEndX/Y/Z is the paired BulletDamage's victim position, letting a
+	// viewer draw a tracer line from the shooter to where the shot landed.
+	// Zero (and omitted) if this shot never hit a player, or its BulletDamage
+	// hadn't arrived before the frame was emitted.
+	TracerEndX float64 `json:"tracer_end_x,omitempty"`
+	TracerEndY float64 `json:"tracer_end_y,omitempty"`
+	TracerEndZ float64 `json:"tracer_end_z,omitempty"`
 }
 
 type ProjectileData struct {
@@ -87,12 +143,41 @@ type ProjectileData struct {
 	Z    float64 `json:"z"`
 }
 
+// DamageEvent records one events.PlayerHurt. IsThroughSmoke and
+// NumPenetrations are always zero-valued: demoinfocs-golang's PlayerHurt
+// event doesn't surface bullet-penetration or smoke-occlusion data, so
+// there's nothing to populate them from.
+type DamageEvent struct {
+	AttackerID      uint64 `json:"attacker_id,omitempty"`
+	VictimID        uint64 `json:"victim_id"`
+	Weapon          string `json:"weapon"`
+	Damage          int    `json:"damage"`
+	DamageArmor     int    `json:"damage_armor"`
+	HitGroup        string `json:"hitgroup"`
+	IsThroughSmoke  bool   `json:"is_through_smoke"`
+	NumPenetrations int    `json:"num_penetrations"`
+	VictimHPAfter   int    `json:"victim_hp_after"`
+}
+
+// ImpactEvent records one events.BulletDamage: where a bullet that hit a
+// player landed. demoinfocs-golang has no generic "bullet landed here"
+// event that also covers misses, so unlike the rest of FrameData this
+// array is confined to confirmed hits.
+type ImpactEvent struct {
+	ShooterID uint64  `json:"shooter_id"`
+	X         float64 `json:"x"`
+	Y         float64 `json:"y"`
+	Z         float64 `json:"z"`
+}
+
 type FrameData struct {
 	Tick        int              `json:"tick"`
 	Players     []PlayerData     `json:"players"`
 	Grenades    []GrenadeEffect  `json:"grenades"`
 	Projectiles []ProjectileData `json:"projectiles"`
 	Fires       []WeaponFire     `json:"fires"`
+	Damages     []DamageEvent    `json:"damages"`
+	Impacts     []ImpactEvent    `json:"impacts"`
 	Bomb        BombData         `json:"bomb"`
 }
 
@@ -103,6 +188,7 @@ type RoundData struct {
 	TScore         int    `json:"t_score"`
 	WinningTeam    string `json:"winning_team,omitempty"` // "CT" or "T"
 	FreezeTimeTick int    `json:"freeze_time_tick"`       // Tick when freeze time ends
+	MVP            uint64 `json:"mvp,omitempty"`          // SteamID64 of the round's most damage dealt
 }
 
 type MatchData struct {
@@ -112,463 +198,359 @@ type MatchData struct {
 	Frames           []FrameData `json:"frames"`
 	Rounds           []RoundData `json:"rounds"`
 	Kills            []KillEvent `json:"kills"`
-	CTScore          int         `json:"ct_score"`         // Final CT Score
-	TScore           int         `json:"t_score"`          // Final T Score
-	MatchStartTick   int         `json:"match_start_tick"` // Tick when match officially started (after knife/restarts)
+	CTScore          int         `json:"ct_score"`             // Final CT Score
+	TScore           int         `json:"t_score"`              // Final T Score
+	MatchStartTick   int         `json:"match_start_tick"`     // Tick when match officially started (after knife/restarts)
+	MapNumber        int         `json:"map_number,omitempty"` // 1-indexed position within a ParseSeries call; 0 outside a series
 }
 
+// ParseDemo parses r and returns the full match as compact JSON.
+// It is equivalent to ParseDemoWithOptions(r, DefaultExportOptions()) and is
+// kept for backward compatibility with existing CLI/WASM callers.
 func ParseDemo(r io.Reader) ([]byte, error) {
-	p := dem.NewParser(r)
-	defer p.Close()
-
-	var mapName string
-	p.RegisterNetMessageHandler(func(m *msg.CDemoFileHeader) {
-		mapName = m.GetMapName()
-	})
-
-	frames := []FrameData{}
-	rounds := []RoundData{}
-	killEvents := []KillEvent{}
+	return ParseDemoWithOptions(r, DefaultExportOptions())
+}
 
-	ctScore := 0
-	tScore := 0
-	baseCTScore := 0 // Score to subtract (from knife round/warmup)
-	baseTScore := 0
-	matchStartTick := -1  // Will be set when match officially starts
-	matchStarted := false // Flag to track if match has started
-
-	type Stats struct {
-		Kills   int
-		Deaths  int
-		Assists int
-		HS      int
+// ParseDemoWithOptions parses r and renders it using opts.Format. CSV output
+// is written to opts.CSVWriter rather than returned, since a multi-file CSV
+// export doesn't fit a single []byte the way JSON/Binary do; the returned
+// []byte is nil in that case.
+func ParseDemoWithOptions(r io.Reader, opts ExportOptions) ([]byte, error) {
+	if opts.TickSkip <= 0 {
+		opts.TickSkip = 4
 	}
-	playerStats := make(map[uint64]*Stats)
 
-	// Roster mapping: SteamID -> RosterIndex (1-10)
-	// CT players: 1-5, T players: 6-10
-	rosterMap := make(map[uint64]int)
-	rosterBuilt := false
+	matchData, err := parseDemoToMatchData(r, opts)
+	if err != nil {
+		return nil, err
+	}
 
-	getStats := func(id uint64) *Stats {
-		if _, ok := playerStats[id]; !ok {
-			playerStats[id] = &Stats{}
+	switch opts.Format {
+	case FormatCSV:
+		if opts.CSVWriter == nil {
+			return nil, fmt.Errorf("ExportOptions.CSVWriter must be set when Format is FormatCSV")
 		}
-		return playerStats[id]
+		return nil, writeMatchDataCSV(matchData, opts.CSVWriter, opts.IncludePositions)
+	case FormatBinary:
+		header, frames := matchDataToBinFrames(matchData)
+		return binfmt.Encode(header, frames)
+	case FormatMinifiedJSON:
+		// encoding/json.Marshal already produces compact, whitespace-free
+		// output, so today this is identical to FormatJSON. Kept as its own
+		// case so a future pretty-printed FormatJSON doesn't silently bloat
+		// MinifiedJSON consumers.
+		return json.Marshal(matchData)
+	default:
+		return json.Marshal(matchData)
 	}
+}
 
-	p.RegisterEventHandler(func(e events.RoundEnd) {
-		gs := p.GameState()
-
-		// Only accumulate scores after match has started
-		if matchStarted {
-			// Subtract the base scores to get actual match score
-			ctScore = gs.TeamCounterTerrorists().Score() - baseCTScore
-			tScore = gs.TeamTerrorists().Score() - baseTScore
-		}
+// parseDemoToMatchData runs the shared event pipeline (runPipeline) over r
+// and accumulates every callback into a MatchData, honoring opts.TickSkip
+// and opts.IncludePositions.
+func parseDemoToMatchData(r io.Reader, opts ExportOptions) (MatchData, error) {
+	if opts.TickSkip <= 0 {
+		opts.TickSkip = 4
+	}
 
-		var winningTeam string
-		if e.Winner == common.TeamCounterTerrorists {
-			winningTeam = "CT"
-		} else if e.Winner == common.TeamTerrorists {
-			winningTeam = "T"
-		}
+	frames := []FrameData{}
+	rounds := []RoundData{}
+	killEvents := []KillEvent{}
 
-		// Update the last round with scores and winning team
-		if len(rounds) > 0 {
-			rounds[len(rounds)-1].CTScore = ctScore
-			rounds[len(rounds)-1].TScore = tScore
-			rounds[len(rounds)-1].WinningTeam = winningTeam
-		}
+	result, err := runPipeline(r, pipelineOptions{
+		IncludePositions: opts.IncludePositions,
+		TickSkip:         opts.TickSkip,
+	}, pipelineCallbacks{
+		onFrame: func(fd FrameData) {
+			frames = append(frames, fd)
+		},
+		onRound: func(rd RoundData) {
+			rounds = append(rounds, rd)
+		},
+		onKill: func(ke KillEvent) {
+			killEvents = append(killEvents, ke)
+		},
+		// onGrenadeStart/onGrenadeEnd are left nil: batch mode only needs a
+		// grenade's presence in FrameData.Grenades, not a start/end event
+		// stream.
 	})
+	if err != nil {
+		return MatchData{}, err
+	}
 
-	p.RegisterEventHandler(func(e events.Kill) {
-		if e.Killer != nil {
-			s := getStats(e.Killer.SteamID64)
-			s.Kills++
-			if e.IsHeadshot {
-				s.HS++
-			}
-		}
-		if e.Victim != nil {
-			getStats(e.Victim.SteamID64).Deaths++
-		}
-		if e.Assister != nil {
-			getStats(e.Assister.SteamID64).Assists++
+	// Attach each player's final match stat line to their entry on the last
+	// frame only: it's a match total, not a per-frame value, and series.go's
+	// aggregation already reads it from exactly that frame.
+	if len(frames) > 0 {
+		last := &frames[len(frames)-1]
+		for i := range last.Players {
+			last.Players[i].Stats = result.PlayerStats[last.Players[i].ID]
 		}
+	}
 
-		ke := KillEvent{
-			Tick:       p.GameState().IngameTick(),
-			KillerID:   0,
-			VictimID:   0,
-			IsHeadshot: e.IsHeadshot,
-			Weapon:     e.Weapon.String(),
-		}
-		if e.Killer != nil {
-			ke.KillerID = e.Killer.SteamID64
-		}
-		if e.Victim != nil {
-			ke.VictimID = e.Victim.SteamID64
-		}
-		if e.Assister != nil {
-			ke.AssisterID = e.Assister.SteamID64
-		}
-		killEvents = append(killEvents, ke)
-	})
+	// Store both original and frame-based tick rates
+	originalTickRate := result.TickRate
+	frameTickRate := result.TickRate / float64(opts.TickSkip)
 
-	// Tracking utilities with unique IDs
-	activeEffects := []GrenadeEffect{}
-	effectIDCounter := int64(0)
-	isBombPlanted := false
-	currentFires := []WeaponFire{}
-	currentTickFlashIDs := []int64{}
-
-	p.RegisterEventHandler(func(e events.WeaponFire) {
-		if e.Shooter != nil {
-			currentFires = append(currentFires, WeaponFire{
-				PlayerID: e.Shooter.SteamID64,
-				Weapon:   e.Weapon.String(),
-			})
-		}
-	})
+	matchData := MatchData{
+		MapName:          result.MapName,
+		TickRate:         frameTickRate,
+		OriginalTickRate: originalTickRate,
+		Frames:           frames,
+		Rounds:           rounds,
+		Kills:            killEvents,
+		CTScore:          result.CTScore,
+		TScore:           result.TScore,
+		MatchStartTick:   result.MatchStartTick,
+	}
 
-	p.RegisterEventHandler(func(e events.PlayerFlashed) {
-		// Attribute flash to all flashes that exploded in this tick
-		for _, id := range currentTickFlashIDs {
-			for i := range activeEffects {
-				if activeEffects[i].ID == id {
-					if e.Player.Team == common.TeamCounterTerrorists {
-						activeEffects[i].FlashedCT++
-					} else if e.Player.Team == common.TeamTerrorists {
-						activeEffects[i].FlashedT++
-					}
-				}
-			}
-		}
-	})
+	return matchData, nil
+}
 
-	// Constants for grenade durations (approximate for CS2)
-	smokeDurationTicks := int(18.0 * 64.0)  // 18s * 64tick
-	molotovDurationTicks := int(7.0 * 64.0) // 7s * 64tick
-	flashDurationTicks := 32                // 0.5s visibility
-
-	p.RegisterEventHandler(func(e events.SmokeStart) {
-		effectIDCounter++
-		start := p.GameState().IngameTick()
-		var entityID int64 = -1
-		if e.Grenade != nil && e.Grenade.Entity != nil {
-			entityID = int64(e.Grenade.Entity.ID())
+// matchDataToBinFrames converts a MatchData into the binfmt package's
+// format-agnostic Header/[]Frame pair, so FormatBinary doesn't need its own
+// copy of the roster/weapon-dictionary bookkeeping already done for JSON.
+// The position reference point is the minimum X/Y/Z seen across every
+// frame, so quantized offsets stay small (and thus cheap to pack) on maps
+// that aren't centered near the origin.
+func matchDataToBinFrames(matchData MatchData) (binfmt.Header, []binfmt.Frame) {
+	roster := map[uint64]string{}
+	rosterIndex := map[uint64]int{}
+	weaponIndex := map[string]int{"": 0}
+	weapons := []string{""}
+
+	refX, refY, refZ := math.MaxFloat64, math.MaxFloat64, math.MaxFloat64
+	haveRef := false
+
+	registerWeapon := func(w string) {
+		if _, ok := weaponIndex[w]; !ok {
+			weaponIndex[w] = len(weapons)
+			weapons = append(weapons, w)
 		}
-		activeEffects = append(activeEffects, GrenadeEffect{
-			ID:        effectIDCounter,
-			EntityID:  entityID,
-			Type:      "SMOKE",
-			X:         e.Position.X,
-			Y:         e.Position.Y,
-			Z:         e.Position.Z,
-			StartTick: start,
-			EndTick:   start + smokeDurationTicks,
-		})
-	})
+	}
 
-	p.RegisterEventHandler(func(e events.SmokeExpired) {
-		if e.Grenade != nil && e.Grenade.Entity != nil {
-			eid := int64(e.Grenade.Entity.ID())
-			// Find and expire early
-			for i := range activeEffects {
-				if activeEffects[i].EntityID == eid && activeEffects[i].Type == "SMOKE" {
-					activeEffects[i].EndTick = p.GameState().IngameTick()
-				}
+	for _, frame := range matchData.Frames {
+		for _, p := range frame.Players {
+			// Frames sampled before match start carry RosterIndex 0, so a
+			// player's entry is refreshed until their assigned index shows up.
+			if _, ok := roster[p.ID]; !ok || rosterIndex[p.ID] == 0 {
+				roster[p.ID] = p.Name
+				rosterIndex[p.ID] = p.RosterIndex
 			}
-		}
-	})
-
-	p.RegisterEventHandler(func(e events.FireGrenadeStart) {
-		effectIDCounter++
-		start := p.GameState().IngameTick()
-		var entityID int64 = -1
-		if e.Grenade != nil && e.Grenade.Entity != nil {
-			entityID = int64(e.Grenade.Entity.ID())
-		}
-		activeEffects = append(activeEffects, GrenadeEffect{
-			ID:        effectIDCounter,
-			EntityID:  entityID,
-			Type:      "MOLOTOV",
-			X:         e.Position.X,
-			Y:         e.Position.Y,
-			Z:         e.Position.Z,
-			StartTick: start,
-			EndTick:   start + molotovDurationTicks,
-		})
-	})
-
-	p.RegisterEventHandler(func(e events.FireGrenadeExpired) {
-		if e.Grenade != nil && e.Grenade.Entity != nil {
-			eid := int64(e.Grenade.Entity.ID())
-			for i := range activeEffects {
-				if activeEffects[i].EntityID == eid && activeEffects[i].Type == "MOLOTOV" {
-					activeEffects[i].EndTick = p.GameState().IngameTick()
-				}
+			registerWeapon(p.ActiveWeapon)
+			if p.X < refX {
+				refX = p.X
+			}
+			if p.Y < refY {
+				refY = p.Y
 			}
+			if p.Z < refZ {
+				refZ = p.Z
+			}
+			haveRef = true
 		}
-	})
-
-	p.RegisterEventHandler(func(e events.FlashExplode) {
-		effectIDCounter++
-		start := p.GameState().IngameTick()
-		activeEffects = append(activeEffects, GrenadeEffect{
-			ID:        effectIDCounter,
-			Type:      "FLASH",
-			X:         e.Position.X,
-			Y:         e.Position.Y,
-			Z:         e.Position.Z,
-			StartTick: start,
-			EndTick:   start + flashDurationTicks,
-		})
-		currentTickFlashIDs = append(currentTickFlashIDs, effectIDCounter)
-	})
-
-	p.RegisterEventHandler(func(e events.HeExplode) {
-		effectIDCounter++
-		start := p.GameState().IngameTick()
-		activeEffects = append(activeEffects, GrenadeEffect{
-			ID:        effectIDCounter,
-			Type:      "HE",
-			X:         e.Position.X,
-			Y:         e.Position.Y,
-			Z:         e.Position.Z,
-			StartTick: start,
-			EndTick:   start + 20,
-		})
-	})
-
-	p.RegisterEventHandler(func(e events.BombPlanted) {
-		isBombPlanted = true
-	})
-
-	p.RegisterEventHandler(func(e events.BombDefused) {
-		isBombPlanted = false
-	})
-
-	p.RegisterEventHandler(func(e events.BombExplode) {
-		isBombPlanted = false
-	})
-
-	// Append rounds only after the actual match has started (exclude pregame/knife/captain rounds)
-	p.RegisterEventHandler(func(e events.RoundStart) {
-		gs := p.GameState()
-		if !gs.IsMatchStarted() {
-			return
+		for _, f := range frame.Fires {
+			registerWeapon(f.Weapon)
 		}
-
-		if !matchStarted {
-			matchStarted = true
-			matchStartTick = gs.IngameTick()
-			// Capture scores at official start to exclude all pregame rounds
-			baseCTScore = gs.TeamCounterTerrorists().Score()
-			baseTScore = gs.TeamTerrorists().Score()
-			// Initialize match scores to 0-0
-			ctScore = 0
-			tScore = 0
-
-			// Build roster map at match start
-			if !rosterBuilt {
-				// Collect CT and T players, sorted by name for stability
-				var ctPlayers []*common.Player
-				var tPlayers []*common.Player
-
-				for _, player := range gs.Participants().Playing() {
-					if player.Team == common.TeamCounterTerrorists {
-						ctPlayers = append(ctPlayers, player)
-					} else if player.Team == common.TeamTerrorists {
-						tPlayers = append(tPlayers, player)
-					}
-				}
-
-				// Sort both teams by name for consistent ordering
-				sort.Slice(ctPlayers, func(i, j int) bool {
-					return ctPlayers[i].Name < ctPlayers[j].Name
-				})
-				sort.Slice(tPlayers, func(i, j int) bool {
-					return tPlayers[i].Name < tPlayers[j].Name
-				})
-
-				// Assign roster indices: CT=1-5, T=6-10
-				for i, player := range ctPlayers {
-					rosterMap[player.SteamID64] = i + 1
-				}
-				for i, player := range tPlayers {
-					rosterMap[player.SteamID64] = 6 + i
-				}
-
-				rosterBuilt = true
-			}
+		for _, d := range frame.Damages {
+			registerWeapon(d.Weapon)
 		}
+	}
+	if !haveRef {
+		refX, refY, refZ = 0, 0, 0
+	}
 
-		roundNum := len(rounds) + 1
-		rounds = append(rounds, RoundData{
-			Number: roundNum,
-			Tick:   gs.IngameTick(),
+	header := binfmt.Header{
+		MapName:          matchData.MapName,
+		TickRate:         matchData.TickRate,
+		OriginalTickRate: matchData.OriginalTickRate,
+		Weapons:          weapons,
+		RefX:             refX,
+		RefY:             refY,
+		RefZ:             refZ,
+	}
+	for id, name := range roster {
+		header.Roster = append(header.Roster, binfmt.RosterEntry{
+			SteamID64:   id,
+			Name:        name,
+			RosterIndex: rosterIndex[id],
 		})
-		activeEffects = []GrenadeEffect{}
-		isBombPlanted = false
-	})
-
-	// Track when freeze time ends for each round
-	p.RegisterEventHandler(func(e events.RoundFreezetimeEnd) {
-		if len(rounds) > 0 {
-			rounds[len(rounds)-1].FreezeTimeTick = p.GameState().IngameTick()
-		}
+	}
+	sort.Slice(header.Roster, func(i, j int) bool {
+		return header.Roster[i].RosterIndex < header.Roster[j].RosterIndex
 	})
 
-	tickSkip := 4
-	currentTickCount := 0
-
-	p.RegisterEventHandler(func(e events.FrameDone) {
-		currentTickCount++
-		if currentTickCount%tickSkip != 0 {
-			return
+	frames := make([]binfmt.Frame, 0, len(matchData.Frames))
+	for _, frame := range matchData.Frames {
+		bf := binfmt.Frame{Tick: frame.Tick, Players: make([]binfmt.Player, 0, len(frame.Players))}
+		for _, p := range frame.Players {
+			bf.Players = append(bf.Players, binfmt.Player{
+				RosterIndex:  p.RosterIndex,
+				Team:         p.Team,
+				IsAlive:      p.IsAlive,
+				X:            p.X,
+				Y:            p.Y,
+				Z:            p.Z,
+				Rotation:     p.Rotation,
+				Hp:           p.Hp,
+				Money:        p.Money,
+				Armor:        p.Armor,
+				HasHelmet:    p.HasHelmet,
+				HasDefuseKit: p.HasDefuseKit,
+				HasBomb:      p.HasBomb,
+				ActiveWeapon: p.ActiveWeapon,
+				Kills:        p.Kills,
+				Deaths:       p.Deaths,
+				Assists:      p.Assists,
+				HS:           p.HS,
+				IsFlashed:    p.IsFlashed,
+				FlashMs:      p.FlashMs,
+			})
 		}
-
-		gameState := p.GameState()
-		currentTick := gameState.IngameTick()
-		currentPlayers := []PlayerData{}
-
-		for _, player := range gameState.Participants().Playing() {
-			teamName := "SPECTATOR"
-			if player.Team == common.TeamTerrorists {
-				teamName = "T"
-			} else if player.Team == common.TeamCounterTerrorists {
-				teamName = "CT"
-			}
-
-			rotation := player.ViewDirectionX()
-			pos := player.Position()
-
-			var weapons []WeaponData
-			hasBomb := false
-			activeWeaponName := ""
-
-			activeWeapon := player.ActiveWeapon()
-			if activeWeapon != nil {
-				activeWeaponName = activeWeapon.String()
-			}
-
-			for _, w := range player.Weapons() {
-				if w.Type == common.EqBomb {
-					hasBomb = true
-				}
-				weapons = append(weapons, WeaponData{
-					Name:  w.String(),
-					Class: fmt.Sprintf("%v", w.Class()),
-				})
-			}
-
-			stats := getStats(player.SteamID64)
-
-			pData := PlayerData{
-				ID:           player.SteamID64,
-				Name:         player.Name,
-				Team:         teamName,
-				IsAlive:      player.IsAlive(),
-				X:            pos.X,
-				Y:            pos.Y,
-				Z:            pos.Z,
-				Rotation:     rotation,
-				Hp:           player.Health(),
-				Money:        player.Money(),
-				Armor:        player.Armor(),
-				HasHelmet:    player.HasHelmet(),
-				HasDefuseKit: player.HasDefuseKit(),
-				HasBomb:      hasBomb,
-				ActiveWeapon: activeWeaponName,
-				Weapons:      weapons,
-				Kills:        stats.Kills,
-				Deaths:       stats.Deaths,
-				Assists:      stats.Assists,
-				HS:           stats.HS,
-				IsFlashed:    player.IsBlinded(),
-				FlashMs:      int(player.FlashDurationTimeRemaining().Milliseconds()),
-				RosterIndex:  rosterMap[player.SteamID64], // Will be 0 if not yet assigned (before match start)
-			}
-			currentPlayers = append(currentPlayers, pData)
+		for _, g := range frame.Grenades {
+			bf.Grenades = append(bf.Grenades, binfmt.Grenade{
+				ID:        g.ID,
+				Type:      g.Type,
+				X:         g.X,
+				Y:         g.Y,
+				Z:         g.Z,
+				StartTick: g.StartTick,
+				EndTick:   g.EndTick,
+				FlashedCT: g.FlashedCT,
+				FlashedT:  g.FlashedT,
+			})
 		}
-
-		// Filter active grenades
-		visibleGrenades := []GrenadeEffect{}
-		remainingEffects := []GrenadeEffect{}
-		for _, eff := range activeEffects {
-			if currentTick <= eff.EndTick {
-				visibleGrenades = append(visibleGrenades, eff)
-				remainingEffects = append(remainingEffects, eff)
-			}
+		for _, pr := range frame.Projectiles {
+			bf.Projectiles = append(bf.Projectiles, binfmt.Projectile{
+				ID:   pr.ID,
+				Type: pr.Type,
+				X:    pr.X,
+				Y:    pr.Y,
+				Z:    pr.Z,
+			})
 		}
-		activeEffects = remainingEffects
-
-		bomb := gameState.Bomb()
-		bombData := BombData{
-			X:         bomb.Position().X,
-			Y:         bomb.Position().Y,
-			Z:         bomb.Position().Z,
-			IsPlanted: isBombPlanted,
+		for _, f := range frame.Fires {
+			bf.Fires = append(bf.Fires, binfmt.Fire{
+				RosterIndex: rosterIndex[f.PlayerID],
+				Weapon:      f.Weapon,
+				Pitch:       f.Pitch,
+				Yaw:         f.Yaw,
+				HasTracer:   f.TracerEndX != 0 || f.TracerEndY != 0 || f.TracerEndZ != 0,
+				TracerEndX:  f.TracerEndX,
+				TracerEndY:  f.TracerEndY,
+				TracerEndZ:  f.TracerEndZ,
+			})
 		}
-		if bomb.Carrier != nil {
-			bombData.CarrierID = bomb.Carrier.SteamID64
+		for _, d := range frame.Damages {
+			bf.Damages = append(bf.Damages, binfmt.Damage{
+				AttackerRosterIndex: rosterIndex[d.AttackerID],
+				VictimRosterIndex:   rosterIndex[d.VictimID],
+				Weapon:              d.Weapon,
+				Damage:              d.Damage,
+				DamageArmor:         d.DamageArmor,
+				HitGroup:            d.HitGroup,
+				VictimHPAfter:       d.VictimHPAfter,
+			})
 		}
-
-		projectiles := []ProjectileData{}
-		for _, p := range gameState.GrenadeProjectiles() {
-			projectiles = append(projectiles, ProjectileData{
-				ID:   int64(p.Entity.ID()),
-				Type: p.WeaponInstance.String(),
-				X:    p.Position().X,
-				Y:    p.Position().Y,
-				Z:    p.Position().Z,
+		for _, im := range frame.Impacts {
+			bf.Impacts = append(bf.Impacts, binfmt.Impact{
+				ShooterRosterIndex: rosterIndex[im.ShooterID],
+				X:                  im.X,
+				Y:                  im.Y,
+				Z:                  im.Z,
 			})
 		}
+		bf.Bomb = binfmt.Bomb{
+			X:                  frame.Bomb.X,
+			Y:                  frame.Bomb.Y,
+			Z:                  frame.Bomb.Z,
+			IsPlanted:          frame.Bomb.IsPlanted,
+			CarrierRosterIndex: rosterIndex[frame.Bomb.CarrierID],
+		}
+		frames = append(frames, bf)
+	}
 
-		frames = append(frames, FrameData{
-			Tick:        currentTick,
-			Players:     currentPlayers,
-			Grenades:    visibleGrenades,
-			Projectiles: projectiles,
-			Fires:       currentFires,
-			Bomb:        bombData,
-		})
-		currentFires = []WeaponFire{}
-		currentTickFlashIDs = []int64{}
-	})
+	return header, frames
+}
 
-	err := p.ParseToEnd()
-	if err != nil && err != dem.ErrUnexpectedEndOfDemo {
-		return nil, err
+// writeMatchDataCSV renders matchData as rounds.csv/kills.csv/players.csv/
+// frames.csv entries in a zip archive written to w, using the exportcsv
+// package so new export formats only need to implement exportcsv.Exporter.
+func writeMatchDataCSV(matchData MatchData, w io.Writer, includePositions bool) error {
+	exporter, err := exportcsv.NewZipExporter(w, includePositions)
+	if err != nil {
+		return err
 	}
 
-	tickRate := p.TickRate()
-	if tickRate <= 0 {
-		tickRate = 64
+	for _, round := range matchData.Rounds {
+		if err := exporter.WriteRound(exportcsv.Round{
+			Number:         round.Number,
+			Tick:           round.Tick,
+			CTScore:        round.CTScore,
+			TScore:         round.TScore,
+			WinningTeam:    round.WinningTeam,
+			FreezeTimeTick: round.FreezeTimeTick,
+		}); err != nil {
+			return err
+		}
 	}
 
-	// Store both original and frame-based tick rates
-	originalTickRate := tickRate
-	frameTickRate := tickRate / float64(tickSkip)
+	for _, kill := range matchData.Kills {
+		if err := exporter.WriteKill(exportcsv.Kill{
+			Tick:       kill.Tick,
+			KillerID:   kill.KillerID,
+			VictimID:   kill.VictimID,
+			AssisterID: kill.AssisterID,
+			IsHeadshot: kill.IsHeadshot,
+			Weapon:     kill.Weapon,
+		}); err != nil {
+			return err
+		}
+	}
 
-	matchData := MatchData{
-		MapName:          mapName,
-		TickRate:         frameTickRate,
-		OriginalTickRate: originalTickRate,
-		Frames:           frames,
-		Rounds:           rounds,
-		Kills:            killEvents,
-		CTScore:          ctScore,
-		TScore:           tScore,
-		MatchStartTick:   matchStartTick,
+	lastSeen := map[uint64]PlayerData{}
+	for _, frame := range matchData.Frames {
+		for _, player := range frame.Players {
+			if err := exporter.WriteFrame(exportcsv.Frame{
+				Tick:     frame.Tick,
+				PlayerID: player.ID,
+				X:        player.X,
+				Y:        player.Y,
+				Z:        player.Z,
+				Hp:       player.Hp,
+				Armor:    player.Armor,
+				IsAlive:  player.IsAlive,
+			}); err != nil {
+				return err
+			}
+			lastSeen[player.ID] = player
+		}
 	}
 
-	jsonData, err := json.Marshal(matchData)
-	if err != nil {
-		return nil, err
+	// players.csv holds one row per player with their final match stats,
+	// in roster order, rather than one row per frame.
+	ids := make([]uint64, 0, len(lastSeen))
+	for id := range lastSeen {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return lastSeen[ids[i]].RosterIndex < lastSeen[ids[j]].RosterIndex
+	})
+	for _, id := range ids {
+		player := lastSeen[id]
+		if err := exporter.WritePlayer(exportcsv.Player{
+			ID:          player.ID,
+			Name:        player.Name,
+			Team:        player.Team,
+			RosterIndex: player.RosterIndex,
+			Kills:       player.Kills,
+			Deaths:      player.Deaths,
+			Assists:     player.Assists,
+			HS:          player.HS,
+		}); err != nil {
+			return err
+		}
 	}
 
-	return jsonData, nil
+	return exporter.Finalize()
 }
@@ -0,0 +1,638 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	dem "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs"
+	common "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/common"
+	events "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/events"
+	msg "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs/msg"
+
+	"github.com/yerevin/cs2-2d-demoviewer/internal/stats"
+)
+
+// pipelineOptions is the subset of ExportOptions/StreamOptions that
+// runPipeline needs: both callers sample positions and ticks the same way,
+// they just differ in what they do with the results.
+type pipelineOptions struct {
+	IncludePositions bool
+	TickSkip         int
+}
+
+// pipelineCallbacks lets a caller observe events as runPipeline produces
+// them, without runPipeline needing to know whether it's accumulating a
+// MatchData (ParseDemoWithOptions) or streaming NDJSON (ParseDemoStream).
+// Every field is optional; a nil callback is simply not invoked.
+type pipelineCallbacks struct {
+	onFrame        func(FrameData)
+	onRound        func(RoundData)
+	onKill         func(KillEvent)
+	onGrenadeStart func(GrenadeEffect)
+	onGrenadeEnd   func(GrenadeEffect)
+}
+
+// pipelineResult carries the values that are only known once p.ParseToEnd
+// has completed.
+type pipelineResult struct {
+	MapName        string
+	TickRate       float64 // Raw demo tick rate (e.g. 64 for CS2), before tickSkip is applied.
+	CTScore        int
+	TScore         int
+	MatchStartTick int
+
+	// PlayerStats is each player's final rich match stat line, keyed by
+	// SteamID64. Unlike the per-frame Kills/Deaths/Assists/HS this is a
+	// match total, so it is only meaningful once the whole demo has been
+	// parsed; runPipeline does not attach it to individual frames.
+	PlayerStats map[uint64]*stats.PlayerMatchStats
+}
+
+// runPipeline drives a single demoinfocs parse of r, registering every event
+// handler shared by ParseDemoWithOptions and ParseDemoStream exactly once.
+// Callers differ only in cb: batch mode accumulates into slices, streaming
+// mode marshals each callback straight to NDJSON. The returned
+// pipelineResult reflects parser state as of the moment ParseToEnd
+// completes, so callers must not read e.g. TickRate/MapName before this
+// function returns.
+func runPipeline(r io.Reader, opts pipelineOptions, cb pipelineCallbacks) (pipelineResult, error) {
+	if opts.TickSkip <= 0 {
+		opts.TickSkip = 4
+	}
+
+	p := dem.NewParser(r)
+	defer p.Close()
+
+	var mapName string
+	p.RegisterNetMessageHandler(func(m *msg.CDemoFileHeader) {
+		mapName = m.GetMapName()
+	})
+
+	ctScore := 0
+	tScore := 0
+	baseCTScore := 0
+	baseTScore := 0
+	matchStartTick := -1
+	matchStarted := false
+
+	type Stats struct {
+		Kills   int
+		Deaths  int
+		Assists int
+		HS      int
+	}
+	playerStats := make(map[uint64]*Stats)
+	getStats := func(id uint64) *Stats {
+		if _, ok := playerStats[id]; !ok {
+			playerStats[id] = &Stats{}
+		}
+		return playerStats[id]
+	}
+
+	// Roster mapping: SteamID -> RosterIndex (1-10). CT players: 1-5, T
+	// players: 6-10, assigned once at match start (sorted by name for
+	// reproducibility across runs of the same demo).
+	rosterMap := make(map[uint64]int)
+	rosterBuilt := false
+
+	statsTracker := stats.NewTracker()
+	teamString := func(t common.Team) string {
+		if t == common.TeamCounterTerrorists {
+			return "CT"
+		} else if t == common.TeamTerrorists {
+			return "T"
+		}
+		return ""
+	}
+
+	var currentRound RoundData
+	numRounds := 0
+
+	p.RegisterEventHandler(func(e events.RoundEnd) {
+		gs := p.GameState()
+		if matchStarted {
+			ctScore = gs.TeamCounterTerrorists().Score() - baseCTScore
+			tScore = gs.TeamTerrorists().Score() - baseTScore
+		}
+
+		var winningTeam string
+		if e.Winner == common.TeamCounterTerrorists {
+			winningTeam = "CT"
+		} else if e.Winner == common.TeamTerrorists {
+			winningTeam = "T"
+		}
+
+		mvp := statsTracker.EndRound(winningTeam)
+
+		if currentRound.Number > 0 {
+			currentRound.CTScore = ctScore
+			currentRound.TScore = tScore
+			currentRound.WinningTeam = winningTeam
+			currentRound.MVP = mvp
+			if cb.onRound != nil {
+				cb.onRound(currentRound)
+			}
+			currentRound = RoundData{}
+		}
+	})
+
+	p.RegisterEventHandler(func(e events.Kill) {
+		if e.Killer != nil {
+			s := getStats(e.Killer.SteamID64)
+			s.Kills++
+			if e.IsHeadshot {
+				s.HS++
+			}
+		}
+		if e.Victim != nil {
+			getStats(e.Victim.SteamID64).Deaths++
+		}
+		if e.Assister != nil {
+			getStats(e.Assister.SteamID64).Assists++
+		}
+
+		ke := KillEvent{
+			Tick:       p.GameState().IngameTick(),
+			IsHeadshot: e.IsHeadshot,
+			Weapon:     e.Weapon.String(),
+		}
+		if e.Killer != nil {
+			ke.KillerID = e.Killer.SteamID64
+		}
+		if e.Victim != nil {
+			ke.VictimID = e.Victim.SteamID64
+		}
+		if e.Assister != nil {
+			ke.AssisterID = e.Assister.SteamID64
+		}
+		if cb.onKill != nil {
+			cb.onKill(ke)
+		}
+
+		if e.Victim != nil {
+			var killerID, assisterID uint64
+			var killerTeam string
+			if e.Killer != nil {
+				killerID = e.Killer.SteamID64
+				killerTeam = teamString(e.Killer.Team)
+			}
+			if e.Assister != nil {
+				assisterID = e.Assister.SteamID64
+			}
+			statsTracker.RecordKill(killerID, e.Victim.SteamID64, assisterID, ke.Tick, killerTeam, teamString(e.Victim.Team))
+		}
+	})
+
+	// currentDamages/currentImpacts buffer events.PlayerHurt/BulletDamage
+	// since the last emitted frame, so a frame emitted under tickSkip > 1
+	// still reports damage/impacts from the ticks it skipped over, rather
+	// than only whatever happened on the exact tick that got sampled.
+	currentDamages := []DamageEvent{}
+	currentImpacts := []ImpactEvent{}
+	// pendingFireByShooter queues the index (into currentFires) of each
+	// shooter's not-yet-paired WeaponFire, FIFO, so the next BulletDamage
+	// from that shooter fills in its TracerEnd.
+	pendingFireByShooter := map[uint64][]int{}
+
+	p.RegisterEventHandler(func(e events.PlayerHurt) {
+		if e.Player == nil {
+			return
+		}
+		weapon := ""
+		if e.Weapon != nil {
+			weapon = e.Weapon.String()
+		}
+
+		de := DamageEvent{
+			VictimID:      e.Player.SteamID64,
+			Weapon:        weapon,
+			Damage:        e.HealthDamageTaken,
+			DamageArmor:   e.ArmorDamageTaken,
+			HitGroup:      fmt.Sprintf("%v", e.HitGroup),
+			VictimHPAfter: e.Health,
+		}
+		if e.Attacker != nil {
+			de.AttackerID = e.Attacker.SteamID64
+		}
+		currentDamages = append(currentDamages, de)
+
+		if e.Attacker != nil {
+			isUtility := e.Weapon != nil && (e.Weapon.Type == common.EqHE || e.Weapon.Type == common.EqMolotov || e.Weapon.Type == common.EqIncendiary)
+			statsTracker.RecordHurt(e.Attacker.SteamID64, e.Player.SteamID64, weapon, e.HealthDamageTaken, isUtility)
+		}
+	})
+
+	activeEffects := []GrenadeEffect{}
+	effectIDCounter := int64(0)
+	isBombPlanted := false
+	currentFires := []WeaponFire{}
+	currentTickFlashIDs := []int64{}
+
+	p.RegisterEventHandler(func(e events.WeaponFire) {
+		if e.Shooter != nil {
+			currentFires = append(currentFires, WeaponFire{
+				PlayerID: e.Shooter.SteamID64,
+				Weapon:   e.Weapon.String(),
+				Pitch:    e.Shooter.ViewDirectionY(),
+				Yaw:      e.Shooter.ViewDirectionX(),
+			})
+			pendingFireByShooter[e.Shooter.SteamID64] = append(pendingFireByShooter[e.Shooter.SteamID64], len(currentFires)-1)
+			statsTracker.RecordWeaponFire(e.Shooter.SteamID64)
+		}
+	})
+
+	// BulletDamage fires once per bullet that hits a player; demoinfocs-golang
+	// has no event for a bullet that misses or hits world geometry, so
+	// tracers/impacts here are only ever drawn for confirmed hits. The
+	// victim's position at the moment of the hit stands in for the impact
+	// point, since BulletDamage itself carries no position.
+	p.RegisterEventHandler(func(e events.BulletDamage) {
+		if e.Attacker == nil || e.Victim == nil {
+			return
+		}
+		pos := e.Victim.Position()
+		currentImpacts = append(currentImpacts, ImpactEvent{
+			ShooterID: e.Attacker.SteamID64,
+			X:         pos.X,
+			Y:         pos.Y,
+			Z:         pos.Z,
+		})
+
+		if pending := pendingFireByShooter[e.Attacker.SteamID64]; len(pending) > 0 {
+			fireIdx := pending[0]
+			pendingFireByShooter[e.Attacker.SteamID64] = pending[1:]
+			if fireIdx < len(currentFires) {
+				currentFires[fireIdx].TracerEndX = pos.X
+				currentFires[fireIdx].TracerEndY = pos.Y
+				currentFires[fireIdx].TracerEndZ = pos.Z
+			}
+		}
+	})
+
+	p.RegisterEventHandler(func(e events.PlayerFlashed) {
+		// Attribute flash to all flashes that exploded in this tick
+		for _, id := range currentTickFlashIDs {
+			for i := range activeEffects {
+				if activeEffects[i].ID == id {
+					if e.Player.Team == common.TeamCounterTerrorists {
+						activeEffects[i].FlashedCT++
+					} else if e.Player.Team == common.TeamTerrorists {
+						activeEffects[i].FlashedT++
+					}
+				}
+			}
+		}
+
+		if e.Attacker != nil {
+			durationMs := int(e.Player.FlashDurationTimeRemaining().Milliseconds())
+			statsTracker.RecordFlashed(e.Attacker.SteamID64, e.Attacker.Team == e.Player.Team, durationMs)
+		}
+	})
+
+	// Constants for grenade durations (approximate for CS2)
+	smokeDurationTicks := int(18.0 * 64.0)  // 18s * 64tick
+	molotovDurationTicks := int(7.0 * 64.0) // 7s * 64tick
+	flashDurationTicks := 32                // 0.5s visibility
+
+	startEffect := func(typ string, pos r3Vec, duration int) *GrenadeEffect {
+		effectIDCounter++
+		start := p.GameState().IngameTick()
+		eff := GrenadeEffect{
+			ID:        effectIDCounter,
+			EntityID:  -1,
+			Type:      typ,
+			X:         pos.X,
+			Y:         pos.Y,
+			Z:         pos.Z,
+			StartTick: start,
+			EndTick:   start + duration,
+		}
+		activeEffects = append(activeEffects, eff)
+		if cb.onGrenadeStart != nil {
+			cb.onGrenadeStart(eff)
+		}
+		return &activeEffects[len(activeEffects)-1]
+	}
+
+	// expireEffect removes the active effect matching entityID/typ, ending it
+	// at the current tick and reporting it via cb.onGrenadeEnd, rather than
+	// leaving it to age out on its own EndTick.
+	expireEffect := func(entityID int64, typ string) {
+		for i, eff := range activeEffects {
+			if eff.EntityID == entityID && eff.Type == typ {
+				eff.EndTick = p.GameState().IngameTick()
+				if cb.onGrenadeEnd != nil {
+					cb.onGrenadeEnd(eff)
+				}
+				activeEffects = append(activeEffects[:i], activeEffects[i+1:]...)
+				break
+			}
+		}
+	}
+
+	p.RegisterEventHandler(func(e events.SmokeStart) {
+		eff := startEffect("SMOKE", r3Vec{e.Position.X, e.Position.Y, e.Position.Z}, smokeDurationTicks)
+		if e.Grenade != nil && e.Grenade.Entity != nil {
+			eff.EntityID = int64(e.Grenade.Entity.ID())
+		}
+	})
+
+	p.RegisterEventHandler(func(e events.SmokeExpired) {
+		if e.Grenade != nil && e.Grenade.Entity != nil {
+			expireEffect(int64(e.Grenade.Entity.ID()), "SMOKE")
+		}
+	})
+
+	p.RegisterEventHandler(func(e events.FireGrenadeStart) {
+		eff := startEffect("MOLOTOV", r3Vec{e.Position.X, e.Position.Y, e.Position.Z}, molotovDurationTicks)
+		if e.Grenade != nil && e.Grenade.Entity != nil {
+			eff.EntityID = int64(e.Grenade.Entity.ID())
+		}
+	})
+
+	p.RegisterEventHandler(func(e events.FireGrenadeExpired) {
+		if e.Grenade != nil && e.Grenade.Entity != nil {
+			expireEffect(int64(e.Grenade.Entity.ID()), "MOLOTOV")
+		}
+	})
+
+	p.RegisterEventHandler(func(e events.FlashExplode) {
+		startEffect("FLASH", r3Vec{e.Position.X, e.Position.Y, e.Position.Z}, flashDurationTicks)
+		currentTickFlashIDs = append(currentTickFlashIDs, effectIDCounter)
+
+		if e.Thrower != nil {
+			statsTracker.RecordFlashThrown(e.Thrower.SteamID64)
+		}
+	})
+
+	p.RegisterEventHandler(func(e events.HeExplode) {
+		startEffect("HE", r3Vec{e.Position.X, e.Position.Y, e.Position.Z}, 20)
+	})
+
+	p.RegisterEventHandler(func(e events.BombPlanted) {
+		isBombPlanted = true
+		if e.Player != nil {
+			statsTracker.RecordBombPlant(e.Player.SteamID64)
+		}
+	})
+
+	p.RegisterEventHandler(func(e events.BombDefused) {
+		isBombPlanted = false
+		if e.Player != nil {
+			statsTracker.RecordBombDefuse(e.Player.SteamID64)
+		}
+	})
+
+	p.RegisterEventHandler(func(e events.BombExplode) {
+		isBombPlanted = false
+	})
+
+	// Append rounds only after the actual match has started (exclude pregame/knife/captain rounds)
+	p.RegisterEventHandler(func(e events.RoundStart) {
+		gs := p.GameState()
+		if !gs.IsMatchStarted() {
+			return
+		}
+
+		if !matchStarted {
+			matchStarted = true
+			matchStartTick = gs.IngameTick()
+			// Capture scores at official start to exclude all pregame rounds
+			baseCTScore = gs.TeamCounterTerrorists().Score()
+			baseTScore = gs.TeamTerrorists().Score()
+			ctScore = 0
+			tScore = 0
+
+			if !rosterBuilt {
+				var ctPlayers []*common.Player
+				var tPlayers []*common.Player
+				for _, player := range gs.Participants().Playing() {
+					if player.Team == common.TeamCounterTerrorists {
+						ctPlayers = append(ctPlayers, player)
+					} else if player.Team == common.TeamTerrorists {
+						tPlayers = append(tPlayers, player)
+					}
+				}
+				sort.Slice(ctPlayers, func(i, j int) bool { return ctPlayers[i].Name < ctPlayers[j].Name })
+				sort.Slice(tPlayers, func(i, j int) bool { return tPlayers[i].Name < tPlayers[j].Name })
+				for i, player := range ctPlayers {
+					rosterMap[player.SteamID64] = i + 1
+				}
+				for i, player := range tPlayers {
+					rosterMap[player.SteamID64] = 6 + i
+				}
+				rosterBuilt = true
+			}
+		}
+
+		numRounds++
+		currentRound = RoundData{
+			Number: numRounds,
+			Tick:   gs.IngameTick(),
+		}
+
+		// Flush any grenade still burning when the round ends (e.g. an 18s
+		// smoke outliving the round): without this, a streaming consumer
+		// that tracks active grenades purely from start/end events would be
+		// left with a permanent phantom entry that never gets a matching end.
+		for _, eff := range activeEffects {
+			eff.EndTick = gs.IngameTick()
+			if cb.onGrenadeEnd != nil {
+				cb.onGrenadeEnd(eff)
+			}
+		}
+		activeEffects = []GrenadeEffect{}
+		isBombPlanted = false
+
+		participants := map[uint64]string{}
+		for _, player := range gs.Participants().Playing() {
+			if player.Team == common.TeamCounterTerrorists {
+				participants[player.SteamID64] = "CT"
+			} else if player.Team == common.TeamTerrorists {
+				participants[player.SteamID64] = "T"
+			}
+		}
+		statsTracker.BeginRound(participants)
+	})
+
+	// Track when freeze time ends for each round
+	p.RegisterEventHandler(func(e events.RoundFreezetimeEnd) {
+		if currentRound.Number > 0 {
+			currentRound.FreezeTimeTick = p.GameState().IngameTick()
+		}
+	})
+
+	tickSkip := opts.TickSkip
+	currentTickCount := 0
+
+	p.RegisterEventHandler(func(e events.FrameDone) {
+		currentTickCount++
+		if currentTickCount%tickSkip != 0 {
+			return
+		}
+
+		gameState := p.GameState()
+		currentTick := gameState.IngameTick()
+		currentPlayers := []PlayerData{}
+
+		for _, player := range gameState.Participants().Playing() {
+			teamName := "SPECTATOR"
+			if player.Team == common.TeamTerrorists {
+				teamName = "T"
+			} else if player.Team == common.TeamCounterTerrorists {
+				teamName = "CT"
+			}
+
+			rotation := player.ViewDirectionX()
+			pos := player.Position()
+			if !opts.IncludePositions {
+				pos.X, pos.Y, pos.Z = 0, 0, 0
+			}
+
+			var weapons []WeaponData
+			hasBomb := false
+			activeWeaponName := ""
+
+			activeWeapon := player.ActiveWeapon()
+			if activeWeapon != nil {
+				activeWeaponName = activeWeapon.String()
+			}
+
+			for _, w := range player.Weapons() {
+				if w.Type == common.EqBomb {
+					hasBomb = true
+				}
+				weapons = append(weapons, WeaponData{
+					Name:  w.String(),
+					Class: fmt.Sprintf("%v", w.Class()),
+				})
+			}
+
+			stats := getStats(player.SteamID64)
+
+			currentPlayers = append(currentPlayers, PlayerData{
+				ID:           player.SteamID64,
+				Name:         player.Name,
+				Team:         teamName,
+				IsAlive:      player.IsAlive(),
+				X:            pos.X,
+				Y:            pos.Y,
+				Z:            pos.Z,
+				Rotation:     rotation,
+				Hp:           player.Health(),
+				Money:        player.Money(),
+				Armor:        player.Armor(),
+				HasHelmet:    player.HasHelmet(),
+				HasDefuseKit: player.HasDefuseKit(),
+				HasBomb:      hasBomb,
+				ActiveWeapon: activeWeaponName,
+				Weapons:      weapons,
+				Kills:        stats.Kills,
+				Deaths:       stats.Deaths,
+				Assists:      stats.Assists,
+				HS:           stats.HS,
+				IsFlashed:    player.IsBlinded(),
+				FlashMs:      int(player.FlashDurationTimeRemaining().Milliseconds()),
+				RosterIndex:  rosterMap[player.SteamID64], // Will be 0 if not yet assigned (before match start)
+			})
+		}
+
+		// Filter active grenades, flushing anything that's aged out since
+		// the last frame.
+		visibleGrenades := []GrenadeEffect{}
+		remainingEffects := []GrenadeEffect{}
+		for _, eff := range activeEffects {
+			if currentTick <= eff.EndTick {
+				visibleGrenades = append(visibleGrenades, eff)
+				remainingEffects = append(remainingEffects, eff)
+			} else if cb.onGrenadeEnd != nil {
+				cb.onGrenadeEnd(eff)
+			}
+		}
+		activeEffects = remainingEffects
+
+		bomb := gameState.Bomb()
+		bombPos := bomb.Position()
+		bombData := BombData{
+			X:         bombPos.X,
+			Y:         bombPos.Y,
+			Z:         bombPos.Z,
+			IsPlanted: isBombPlanted,
+		}
+		if bomb.Carrier != nil {
+			bombData.CarrierID = bomb.Carrier.SteamID64
+		}
+
+		projectiles := []ProjectileData{}
+		for _, gp := range gameState.GrenadeProjectiles() {
+			projPos := gp.Position()
+			projectiles = append(projectiles, ProjectileData{
+				ID:   int64(gp.Entity.ID()),
+				Type: gp.WeaponInstance.String(),
+				X:    projPos.X,
+				Y:    projPos.Y,
+				Z:    projPos.Z,
+			})
+		}
+
+		if !opts.IncludePositions {
+			bombData.X, bombData.Y, bombData.Z = 0, 0, 0
+			for i := range projectiles {
+				projectiles[i].X, projectiles[i].Y, projectiles[i].Z = 0, 0, 0
+			}
+			for i := range visibleGrenades {
+				visibleGrenades[i].X, visibleGrenades[i].Y, visibleGrenades[i].Z = 0, 0, 0
+			}
+			for i := range currentImpacts {
+				currentImpacts[i].X, currentImpacts[i].Y, currentImpacts[i].Z = 0, 0, 0
+			}
+			for i := range currentFires {
+				currentFires[i].TracerEndX, currentFires[i].TracerEndY, currentFires[i].TracerEndZ = 0, 0, 0
+			}
+		}
+
+		if cb.onFrame != nil {
+			cb.onFrame(FrameData{
+				Tick:        currentTick,
+				Players:     currentPlayers,
+				Grenades:    visibleGrenades,
+				Projectiles: projectiles,
+				Fires:       currentFires,
+				Damages:     currentDamages,
+				Impacts:     currentImpacts,
+				Bomb:        bombData,
+			})
+		}
+		currentFires = []WeaponFire{}
+		currentDamages = []DamageEvent{}
+		currentImpacts = []ImpactEvent{}
+		currentTickFlashIDs = []int64{}
+		pendingFireByShooter = map[uint64][]int{}
+	})
+
+	err := p.ParseToEnd()
+	if err != nil && err != dem.ErrUnexpectedEndOfDemo {
+		return pipelineResult{}, err
+	}
+
+	tickRate := p.TickRate()
+	if tickRate <= 0 {
+		tickRate = 64
+	}
+
+	return pipelineResult{
+		MapName:        mapName,
+		TickRate:       tickRate,
+		CTScore:        ctScore,
+		TScore:         tScore,
+		MatchStartTick: matchStartTick,
+		PlayerStats:    statsTracker.Snapshot(),
+	}, nil
+}
+
+// r3Vec is a minimal position tuple, used so startEffect doesn't need to
+// import the demoinfocs r3 vector type directly.
+type r3Vec struct {
+	X, Y, Z float64
+}
@@ -0,0 +1,806 @@
+// Package binfmt implements Format=Binary: a compact, self-describing
+// bit-packed encoding of a parsed demo's frame stream, targeting roughly a
+// 10x size reduction versus the equivalent JSON. It trades the simplicity
+// of JSON for quantized fields (HP/armor/money/rotation/position) and
+// delta records (a frame only re-sends a player's fields that changed
+// since the last frame), in the same spirit as RTS replay formats like
+// SC2's.
+//
+// Encode takes a format-agnostic Header/[]Frame pair (mirroring, but not
+// importing, the parser's own MatchData/PlayerData types, the same way
+// internal/export/csv does) and Decode reconstructs it losslessly except
+// for the documented quantization of HP/armor/money/rotation/position.
+package binfmt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Bit widths for each quantized field. Exported so callers (and the
+// conformance test) can reason about quantization tolerance.
+const (
+	HPBits       = 7  // 0-127
+	ArmorBits    = 7  // 0-127
+	MoneyBits    = 15 // 0-32767
+	RotationBits = 12 // 0-4095, mapped from 0-360 degrees
+	PositionBits = 16 // signed, quantized offset from the header reference point
+
+	// PositionScale is the size of one quantization step in engine units
+	// (offsets are divided by it). CS2 maps are at most ~8192 units across;
+	// at this scale a 16-bit signed offset (+/-32767 steps) covers
+	// +/-~65534 units around the reference point, i.e. the whole map, at
+	// 2-unit resolution.
+	PositionScale = 2.0
+
+	flagsBits  = 7 // alive, helmet, defuse kit, has bomb, is flashed + 2-bit team code
+	statsBits  = 6 // each of kills/deaths/assists/hs
+	flashMsMax = 1<<12 - 1
+)
+
+// changeBit indexes into a player delta record's change bitmask.
+type changeBit uint
+
+const (
+	changeFlags changeBit = iota
+	changeHPArmor
+	changeMoney
+	changeRotation
+	changePosition
+	changeWeapon
+	changeCombatStats
+	changeFlashMs
+	numChangeBits
+)
+
+// RosterEntry is one player's identity, written once in the header so
+// per-frame records can reference a player by its small RosterIndex
+// instead of repeating the 8-byte SteamID64 every frame.
+type RosterEntry struct {
+	SteamID64   uint64
+	Name        string
+	RosterIndex int // 1-10, matches the JSON export's PlayerData.RosterIndex
+}
+
+// Header is the self-describing preamble: map name, tick rate, roster and
+// weapon dictionary, plus the reference point positions are quantized
+// against.
+type Header struct {
+	MapName          string
+	TickRate         float64
+	OriginalTickRate float64
+	Roster           []RosterEntry
+	Weapons          []string // index 0 is reserved for "" (no active weapon)
+	RefX, RefY, RefZ float64  // position quantization reference point
+}
+
+// Player is one player's state within a Frame. It mirrors PlayerData's
+// fields relevant to the viewer, minus the few (Name, Weapons list) that
+// are redundant with the header or omitted from the compact format.
+type Player struct {
+	RosterIndex  int
+	Team         string // "CT", "T" or "SPECTATOR"
+	IsAlive      bool
+	X, Y, Z      float64
+	Rotation     float32
+	Hp           int
+	Money        int
+	Armor        int
+	HasHelmet    bool
+	HasDefuseKit bool
+	HasBomb      bool
+	ActiveWeapon string
+	Kills        int
+	Deaths       int
+	Assists      int
+	HS           int
+	IsFlashed    bool
+	FlashMs      int
+}
+
+// Frame is one sampled tick's player states, plus every other per-tick
+// entity the JSON export carries (grenades, projectiles, weapon fires,
+// damage/impact events and bomb state). Earlier versions of this format
+// only carried Players, silently dropping the rest on the Binary export
+// path; all of it now round-trips through Encode/Decode like Players does.
+type Frame struct {
+	Tick        int
+	Players     []Player
+	Grenades    []Grenade
+	Projectiles []Projectile
+	Fires       []Fire
+	Damages     []Damage
+	Impacts     []Impact
+	Bomb        Bomb
+}
+
+// Grenade mirrors the parser's GrenadeEffect: a timed smoke/flash/HE/
+// molotov effect with its lifetime and (for flashes) who it blinded.
+type Grenade struct {
+	ID        int64
+	Type      string // "SMOKE", "FLASH", "HE" or "MOLOTOV"
+	X, Y, Z   float64
+	StartTick int
+	EndTick   int
+	FlashedCT int
+	FlashedT  int
+}
+
+// Projectile mirrors the parser's ProjectileData: an in-flight grenade that
+// hasn't detonated yet.
+type Projectile struct {
+	ID      int64
+	Type    string
+	X, Y, Z float64
+}
+
+// Fire mirrors the parser's WeaponFire, with the shooter identified by
+// RosterIndex instead of SteamID64.
+type Fire struct {
+	RosterIndex                        int
+	Weapon                             string
+	Pitch, Yaw                         float32
+	HasTracer                          bool
+	TracerEndX, TracerEndY, TracerEndZ float64
+}
+
+// Damage mirrors the parser's DamageEvent, with attacker/victim identified
+// by RosterIndex instead of SteamID64. AttackerRosterIndex is 0 when the
+// source event had no attacker (e.g. fall damage), matching the JSON
+// export's zero value.
+type Damage struct {
+	AttackerRosterIndex int
+	VictimRosterIndex   int
+	Weapon              string
+	Damage              int
+	DamageArmor         int
+	HitGroup            string
+	VictimHPAfter       int
+}
+
+// Impact mirrors the parser's ImpactEvent, with the shooter identified by
+// RosterIndex instead of SteamID64.
+type Impact struct {
+	ShooterRosterIndex int
+	X, Y, Z            float64
+}
+
+// Bomb mirrors the parser's BombData, with the carrier identified by
+// RosterIndex instead of SteamID64 (0 if no carrier).
+type Bomb struct {
+	X, Y, Z            float64
+	IsPlanted          bool
+	CarrierRosterIndex int
+}
+
+// teamCode/teamFromCode map the 3 team strings the parser emits to/from a
+// 2-bit code so Team travels in the flags group instead of needing its own
+// string table entry.
+func teamCode(team string) uint64 {
+	switch team {
+	case "CT":
+		return 1
+	case "T":
+		return 2
+	default:
+		return 0 // "SPECTATOR" or unknown
+	}
+}
+
+func teamFromCode(code uint64) string {
+	switch code {
+	case 1:
+		return "CT"
+	case 2:
+		return "T"
+	default:
+		return "SPECTATOR"
+	}
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+func weaponIDBits(n int) int {
+	bits := 1
+	for (1 << uint(bits)) < n {
+		bits++
+	}
+	return bits
+}
+
+// Encode writes header and frames as the bit-packed binary format
+// described in the package doc.
+func Encode(header Header, frames []Frame) ([]byte, error) {
+	var out bytes.Buffer
+
+	if err := writeHeader(&out, header); err != nil {
+		return nil, err
+	}
+
+	weaponIndex := make(map[string]int, len(header.Weapons))
+	for i, w := range header.Weapons {
+		weaponIndex[w] = i
+	}
+	wBits := weaponIDBits(len(header.Weapons))
+	includePositions := true // callers that don't want positions should omit X/Y/Z (left at 0) and set RefX/Y/Z accordingly; the format always reserves the bits
+
+	binary.Write(&out, binary.BigEndian, uint32(len(frames)))
+
+	last := map[int]Player{} // RosterIndex -> last-sent record, for delta encoding
+	for _, frame := range frames {
+		bw := &bitWriter{}
+		binary.Write(&out, binary.BigEndian, uint32(frame.Tick))
+		binary.Write(&out, binary.BigEndian, uint16(len(frame.Players)))
+
+		for _, p := range frame.Players {
+			bw.WriteBits(uint64(p.RosterIndex), 4)
+
+			prev, known := last[p.RosterIndex]
+			full := !known
+			bw.WriteBool(full)
+
+			mask := uint64(0)
+			if !full {
+				mask = changeMask(prev, p)
+				bw.WriteBits(mask, int(numChangeBits))
+			}
+
+			writeField := func(bit changeBit) bool { return full || mask&(1<<bit) != 0 }
+
+			if writeField(changeFlags) {
+				bw.WriteBool(p.IsAlive)
+				bw.WriteBool(p.HasHelmet)
+				bw.WriteBool(p.HasDefuseKit)
+				bw.WriteBool(p.HasBomb)
+				bw.WriteBool(p.IsFlashed)
+				bw.WriteBits(teamCode(p.Team), 2)
+			}
+			if writeField(changeHPArmor) {
+				bw.WriteBits(uint64(clamp(p.Hp, 0, 1<<HPBits-1)), HPBits)
+				bw.WriteBits(uint64(clamp(p.Armor, 0, 1<<ArmorBits-1)), ArmorBits)
+			}
+			if writeField(changeMoney) {
+				bw.WriteBits(uint64(clamp(p.Money, 0, 1<<MoneyBits-1)), MoneyBits)
+			}
+			if writeField(changeRotation) {
+				deg := math.Mod(float64(p.Rotation)+360, 360)
+				q := int(deg / 360 * (1 << RotationBits))
+				bw.WriteBits(uint64(clamp(q, 0, 1<<RotationBits-1)), RotationBits)
+			}
+			if includePositions && writeField(changePosition) {
+				writeQuantizedAxis(bw, p.X-header.RefX)
+				writeQuantizedAxis(bw, p.Y-header.RefY)
+				writeQuantizedAxis(bw, p.Z-header.RefZ)
+			}
+			if writeField(changeWeapon) {
+				id, ok := weaponIndex[p.ActiveWeapon]
+				if !ok {
+					id = 0
+				}
+				bw.WriteBits(uint64(id), wBits)
+			}
+			if writeField(changeCombatStats) {
+				bw.WriteBits(uint64(clamp(p.Kills, 0, 1<<statsBits-1)), statsBits)
+				bw.WriteBits(uint64(clamp(p.Deaths, 0, 1<<statsBits-1)), statsBits)
+				bw.WriteBits(uint64(clamp(p.Assists, 0, 1<<statsBits-1)), statsBits)
+				bw.WriteBits(uint64(clamp(p.HS, 0, 1<<statsBits-1)), statsBits)
+			}
+			if writeField(changeFlashMs) {
+				bw.WriteBits(uint64(clamp(p.FlashMs, 0, flashMsMax)), 12)
+			}
+
+			last[p.RosterIndex] = p
+		}
+
+		payload := bw.Bytes()
+		binary.Write(&out, binary.BigEndian, uint32(len(payload)))
+		out.Write(payload)
+
+		writeGrenades(&out, header, frame.Grenades)
+		writeProjectiles(&out, header, frame.Projectiles)
+		writeFires(&out, header, weaponIndex, frame.Fires)
+		writeDamages(&out, header, weaponIndex, frame.Damages)
+		writeImpacts(&out, header, frame.Impacts)
+		writeBomb(&out, header, frame.Bomb)
+	}
+
+	return out.Bytes(), nil
+}
+
+func writeGrenades(w io.Writer, h Header, gs []Grenade) {
+	binary.Write(w, binary.BigEndian, uint16(len(gs)))
+	for _, g := range gs {
+		binary.Write(w, binary.BigEndian, g.ID)
+		writeString(w, g.Type)
+		writeQuantizedAxisPlain(w, g.X-h.RefX)
+		writeQuantizedAxisPlain(w, g.Y-h.RefY)
+		writeQuantizedAxisPlain(w, g.Z-h.RefZ)
+		binary.Write(w, binary.BigEndian, int32(g.StartTick))
+		binary.Write(w, binary.BigEndian, int32(g.EndTick))
+		binary.Write(w, binary.BigEndian, uint16(g.FlashedCT))
+		binary.Write(w, binary.BigEndian, uint16(g.FlashedT))
+	}
+}
+
+func writeProjectiles(w io.Writer, h Header, ps []Projectile) {
+	binary.Write(w, binary.BigEndian, uint16(len(ps)))
+	for _, p := range ps {
+		binary.Write(w, binary.BigEndian, p.ID)
+		writeString(w, p.Type)
+		writeQuantizedAxisPlain(w, p.X-h.RefX)
+		writeQuantizedAxisPlain(w, p.Y-h.RefY)
+		writeQuantizedAxisPlain(w, p.Z-h.RefZ)
+	}
+}
+
+func writeFires(w io.Writer, h Header, weaponIndex map[string]int, fires []Fire) {
+	binary.Write(w, binary.BigEndian, uint16(len(fires)))
+	for _, f := range fires {
+		binary.Write(w, binary.BigEndian, uint8(f.RosterIndex))
+		binary.Write(w, binary.BigEndian, uint16(weaponIndex[f.Weapon]))
+		binary.Write(w, binary.BigEndian, f.Pitch)
+		binary.Write(w, binary.BigEndian, f.Yaw)
+		binary.Write(w, binary.BigEndian, f.HasTracer)
+		if f.HasTracer {
+			writeQuantizedAxisPlain(w, f.TracerEndX-h.RefX)
+			writeQuantizedAxisPlain(w, f.TracerEndY-h.RefY)
+			writeQuantizedAxisPlain(w, f.TracerEndZ-h.RefZ)
+		}
+	}
+}
+
+func writeDamages(w io.Writer, h Header, weaponIndex map[string]int, damages []Damage) {
+	binary.Write(w, binary.BigEndian, uint16(len(damages)))
+	for _, d := range damages {
+		binary.Write(w, binary.BigEndian, uint8(d.AttackerRosterIndex))
+		binary.Write(w, binary.BigEndian, uint8(d.VictimRosterIndex))
+		binary.Write(w, binary.BigEndian, uint16(weaponIndex[d.Weapon]))
+		binary.Write(w, binary.BigEndian, int16(d.Damage))
+		binary.Write(w, binary.BigEndian, int16(d.DamageArmor))
+		writeString(w, d.HitGroup)
+		binary.Write(w, binary.BigEndian, int16(d.VictimHPAfter))
+	}
+}
+
+func writeImpacts(w io.Writer, h Header, impacts []Impact) {
+	binary.Write(w, binary.BigEndian, uint16(len(impacts)))
+	for _, im := range impacts {
+		binary.Write(w, binary.BigEndian, uint8(im.ShooterRosterIndex))
+		writeQuantizedAxisPlain(w, im.X-h.RefX)
+		writeQuantizedAxisPlain(w, im.Y-h.RefY)
+		writeQuantizedAxisPlain(w, im.Z-h.RefZ)
+	}
+}
+
+func writeBomb(w io.Writer, h Header, b Bomb) {
+	binary.Write(w, binary.BigEndian, b.IsPlanted)
+	binary.Write(w, binary.BigEndian, uint8(b.CarrierRosterIndex))
+	writeQuantizedAxisPlain(w, b.X-h.RefX)
+	writeQuantizedAxisPlain(w, b.Y-h.RefY)
+	writeQuantizedAxisPlain(w, b.Z-h.RefZ)
+}
+
+// writeQuantizedAxis writes one position axis as a PositionBits-wide
+// signed, clamped, PositionScale-quantized offset.
+func writeQuantizedAxis(bw *bitWriter, offset float64) {
+	q := int(offset / PositionScale)
+	lo, hi := -(1 << (PositionBits - 1)), 1<<(PositionBits-1)-1
+	q = clamp(q, lo, hi)
+	bw.WriteBits(uint64(uint16(int16(q))), PositionBits)
+}
+
+func readQuantizedAxis(br *bitReader, ref float64) float64 {
+	raw := int16(br.ReadBits(PositionBits))
+	return ref + float64(raw)*PositionScale
+}
+
+// writeQuantizedAxisPlain/readQuantizedAxisPlain quantize a position axis
+// the same way writeQuantizedAxis/readQuantizedAxis do, but as a plain
+// 2-byte field rather than a bit-packed one: the per-frame entity lists
+// below (grenades, fires, ...) are sparse enough that bit-packing them
+// isn't worth the added complexity the way it is for the per-player record
+// that repeats every frame.
+func writeQuantizedAxisPlain(w io.Writer, offset float64) error {
+	q := int(offset / PositionScale)
+	lo, hi := -(1 << (PositionBits - 1)), 1<<(PositionBits-1)-1
+	q = clamp(q, lo, hi)
+	return binary.Write(w, binary.BigEndian, int16(q))
+}
+
+func readQuantizedAxisPlain(r io.Reader, ref float64) (float64, error) {
+	var raw int16
+	if err := binary.Read(r, binary.BigEndian, &raw); err != nil {
+		return 0, err
+	}
+	return ref + float64(raw)*PositionScale, nil
+}
+
+// changeMask reports which field groups differ between prev and p.
+func changeMask(prev, p Player) uint64 {
+	var mask uint64
+	if prev.IsAlive != p.IsAlive || prev.HasHelmet != p.HasHelmet || prev.HasDefuseKit != p.HasDefuseKit ||
+		prev.HasBomb != p.HasBomb || prev.IsFlashed != p.IsFlashed || prev.Team != p.Team {
+		mask |= 1 << changeFlags
+	}
+	if prev.Hp != p.Hp || prev.Armor != p.Armor {
+		mask |= 1 << changeHPArmor
+	}
+	if prev.Money != p.Money {
+		mask |= 1 << changeMoney
+	}
+	if prev.Rotation != p.Rotation {
+		mask |= 1 << changeRotation
+	}
+	if prev.X != p.X || prev.Y != p.Y || prev.Z != p.Z {
+		mask |= 1 << changePosition
+	}
+	if prev.ActiveWeapon != p.ActiveWeapon {
+		mask |= 1 << changeWeapon
+	}
+	if prev.Kills != p.Kills || prev.Deaths != p.Deaths || prev.Assists != p.Assists || prev.HS != p.HS {
+		mask |= 1 << changeCombatStats
+	}
+	if prev.FlashMs != p.FlashMs {
+		mask |= 1 << changeFlashMs
+	}
+	return mask
+}
+
+func writeHeader(w io.Writer, h Header) error {
+	writeString(w, h.MapName)
+	binary.Write(w, binary.BigEndian, h.TickRate)
+	binary.Write(w, binary.BigEndian, h.OriginalTickRate)
+	binary.Write(w, binary.BigEndian, h.RefX)
+	binary.Write(w, binary.BigEndian, h.RefY)
+	binary.Write(w, binary.BigEndian, h.RefZ)
+
+	binary.Write(w, binary.BigEndian, uint16(len(h.Roster)))
+	for _, r := range h.Roster {
+		binary.Write(w, binary.BigEndian, r.SteamID64)
+		binary.Write(w, binary.BigEndian, uint8(r.RosterIndex))
+		writeString(w, r.Name)
+	}
+
+	binary.Write(w, binary.BigEndian, uint16(len(h.Weapons)))
+	for _, wpn := range h.Weapons {
+		writeString(w, wpn)
+	}
+	return nil
+}
+
+func writeString(w io.Writer, s string) {
+	binary.Write(w, binary.BigEndian, uint16(len(s)))
+	io.WriteString(w, s)
+}
+
+func readString(r io.Reader) (string, error) {
+	var n uint16
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return "", err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// Decode reconstructs the Header and []Frame written by Encode.
+func Decode(data []byte) (Header, []Frame, error) {
+	r := bytes.NewReader(data)
+	var h Header
+
+	var err error
+	if h.MapName, err = readString(r); err != nil {
+		return h, nil, fmt.Errorf("binfmt: reading map name: %w", err)
+	}
+	binary.Read(r, binary.BigEndian, &h.TickRate)
+	binary.Read(r, binary.BigEndian, &h.OriginalTickRate)
+	binary.Read(r, binary.BigEndian, &h.RefX)
+	binary.Read(r, binary.BigEndian, &h.RefY)
+	binary.Read(r, binary.BigEndian, &h.RefZ)
+
+	var rosterLen uint16
+	binary.Read(r, binary.BigEndian, &rosterLen)
+	h.Roster = make([]RosterEntry, rosterLen)
+	for i := range h.Roster {
+		binary.Read(r, binary.BigEndian, &h.Roster[i].SteamID64)
+		var idx uint8
+		binary.Read(r, binary.BigEndian, &idx)
+		h.Roster[i].RosterIndex = int(idx)
+		if h.Roster[i].Name, err = readString(r); err != nil {
+			return h, nil, fmt.Errorf("binfmt: reading roster name: %w", err)
+		}
+	}
+
+	var weaponLen uint16
+	binary.Read(r, binary.BigEndian, &weaponLen)
+	h.Weapons = make([]string, weaponLen)
+	for i := range h.Weapons {
+		if h.Weapons[i], err = readString(r); err != nil {
+			return h, nil, fmt.Errorf("binfmt: reading weapon dictionary: %w", err)
+		}
+	}
+	wBits := weaponIDBits(len(h.Weapons))
+
+	var numFrames uint32
+	binary.Read(r, binary.BigEndian, &numFrames)
+
+	frames := make([]Frame, 0, numFrames)
+	last := map[int]Player{}
+	for i := uint32(0); i < numFrames; i++ {
+		var tick uint32
+		var numPlayers uint16
+		binary.Read(r, binary.BigEndian, &tick)
+		binary.Read(r, binary.BigEndian, &numPlayers)
+
+		var payloadLen uint32
+		binary.Read(r, binary.BigEndian, &payloadLen)
+		payload := make([]byte, payloadLen)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return h, nil, fmt.Errorf("binfmt: reading frame %d payload: %w", i, err)
+		}
+		br := newBitReader(payload)
+
+		frame := Frame{Tick: int(tick), Players: make([]Player, 0, numPlayers)}
+		for pi := 0; pi < int(numPlayers); pi++ {
+			rosterIndex := int(br.ReadBits(4))
+			full := br.ReadBool()
+
+			p := last[rosterIndex]
+			p.RosterIndex = rosterIndex
+
+			var mask uint64
+			if !full {
+				mask = br.ReadBits(int(numChangeBits))
+			}
+			has := func(bit changeBit) bool { return full || mask&(1<<bit) != 0 }
+
+			if has(changeFlags) {
+				p.IsAlive = br.ReadBool()
+				p.HasHelmet = br.ReadBool()
+				p.HasDefuseKit = br.ReadBool()
+				p.HasBomb = br.ReadBool()
+				p.IsFlashed = br.ReadBool()
+				p.Team = teamFromCode(br.ReadBits(2))
+			}
+			if has(changeHPArmor) {
+				p.Hp = int(br.ReadBits(HPBits))
+				p.Armor = int(br.ReadBits(ArmorBits))
+			}
+			if has(changeMoney) {
+				p.Money = int(br.ReadBits(MoneyBits))
+			}
+			if has(changeRotation) {
+				q := br.ReadBits(RotationBits)
+				p.Rotation = float32(float64(q) / (1 << RotationBits) * 360)
+			}
+			if has(changePosition) {
+				p.X = readQuantizedAxis(br, h.RefX)
+				p.Y = readQuantizedAxis(br, h.RefY)
+				p.Z = readQuantizedAxis(br, h.RefZ)
+			}
+			if has(changeWeapon) {
+				id := int(br.ReadBits(wBits))
+				if id >= 0 && id < len(h.Weapons) {
+					p.ActiveWeapon = h.Weapons[id]
+				}
+			}
+			if has(changeCombatStats) {
+				p.Kills = int(br.ReadBits(statsBits))
+				p.Deaths = int(br.ReadBits(statsBits))
+				p.Assists = int(br.ReadBits(statsBits))
+				p.HS = int(br.ReadBits(statsBits))
+			}
+			if has(changeFlashMs) {
+				p.FlashMs = int(br.ReadBits(12))
+			}
+
+			last[rosterIndex] = p
+			frame.Players = append(frame.Players, p)
+		}
+
+		var err error
+		if frame.Grenades, err = readGrenades(r, h); err != nil {
+			return h, nil, fmt.Errorf("binfmt: reading frame %d grenades: %w", i, err)
+		}
+		if frame.Projectiles, err = readProjectiles(r, h); err != nil {
+			return h, nil, fmt.Errorf("binfmt: reading frame %d projectiles: %w", i, err)
+		}
+		if frame.Fires, err = readFires(r, h); err != nil {
+			return h, nil, fmt.Errorf("binfmt: reading frame %d fires: %w", i, err)
+		}
+		if frame.Damages, err = readDamages(r, h); err != nil {
+			return h, nil, fmt.Errorf("binfmt: reading frame %d damages: %w", i, err)
+		}
+		if frame.Impacts, err = readImpacts(r, h); err != nil {
+			return h, nil, fmt.Errorf("binfmt: reading frame %d impacts: %w", i, err)
+		}
+		if frame.Bomb, err = readBomb(r, h); err != nil {
+			return h, nil, fmt.Errorf("binfmt: reading frame %d bomb: %w", i, err)
+		}
+
+		frames = append(frames, frame)
+	}
+
+	return h, frames, nil
+}
+
+func readGrenades(r io.Reader, h Header) ([]Grenade, error) {
+	var n uint16
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, err
+	}
+	gs := make([]Grenade, n)
+	for i := range gs {
+		binary.Read(r, binary.BigEndian, &gs[i].ID)
+		var err error
+		if gs[i].Type, err = readString(r); err != nil {
+			return nil, err
+		}
+		if gs[i].X, err = readQuantizedAxisPlain(r, h.RefX); err != nil {
+			return nil, err
+		}
+		if gs[i].Y, err = readQuantizedAxisPlain(r, h.RefY); err != nil {
+			return nil, err
+		}
+		if gs[i].Z, err = readQuantizedAxisPlain(r, h.RefZ); err != nil {
+			return nil, err
+		}
+		var startTick, endTick int32
+		binary.Read(r, binary.BigEndian, &startTick)
+		binary.Read(r, binary.BigEndian, &endTick)
+		gs[i].StartTick, gs[i].EndTick = int(startTick), int(endTick)
+		var flashedCT, flashedT uint16
+		binary.Read(r, binary.BigEndian, &flashedCT)
+		binary.Read(r, binary.BigEndian, &flashedT)
+		gs[i].FlashedCT, gs[i].FlashedT = int(flashedCT), int(flashedT)
+	}
+	return gs, nil
+}
+
+func readProjectiles(r io.Reader, h Header) ([]Projectile, error) {
+	var n uint16
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, err
+	}
+	ps := make([]Projectile, n)
+	for i := range ps {
+		binary.Read(r, binary.BigEndian, &ps[i].ID)
+		var err error
+		if ps[i].Type, err = readString(r); err != nil {
+			return nil, err
+		}
+		if ps[i].X, err = readQuantizedAxisPlain(r, h.RefX); err != nil {
+			return nil, err
+		}
+		if ps[i].Y, err = readQuantizedAxisPlain(r, h.RefY); err != nil {
+			return nil, err
+		}
+		if ps[i].Z, err = readQuantizedAxisPlain(r, h.RefZ); err != nil {
+			return nil, err
+		}
+	}
+	return ps, nil
+}
+
+func readFires(r io.Reader, h Header) ([]Fire, error) {
+	var n uint16
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, err
+	}
+	fires := make([]Fire, n)
+	for i := range fires {
+		var rosterIndex uint8
+		binary.Read(r, binary.BigEndian, &rosterIndex)
+		fires[i].RosterIndex = int(rosterIndex)
+		var weaponID uint16
+		binary.Read(r, binary.BigEndian, &weaponID)
+		if int(weaponID) < len(h.Weapons) {
+			fires[i].Weapon = h.Weapons[weaponID]
+		}
+		binary.Read(r, binary.BigEndian, &fires[i].Pitch)
+		binary.Read(r, binary.BigEndian, &fires[i].Yaw)
+		binary.Read(r, binary.BigEndian, &fires[i].HasTracer)
+		if fires[i].HasTracer {
+			var err error
+			if fires[i].TracerEndX, err = readQuantizedAxisPlain(r, h.RefX); err != nil {
+				return nil, err
+			}
+			if fires[i].TracerEndY, err = readQuantizedAxisPlain(r, h.RefY); err != nil {
+				return nil, err
+			}
+			if fires[i].TracerEndZ, err = readQuantizedAxisPlain(r, h.RefZ); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return fires, nil
+}
+
+func readDamages(r io.Reader, h Header) ([]Damage, error) {
+	var n uint16
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, err
+	}
+	damages := make([]Damage, n)
+	for i := range damages {
+		var attacker, victim uint8
+		binary.Read(r, binary.BigEndian, &attacker)
+		binary.Read(r, binary.BigEndian, &victim)
+		damages[i].AttackerRosterIndex = int(attacker)
+		damages[i].VictimRosterIndex = int(victim)
+		var weaponID uint16
+		binary.Read(r, binary.BigEndian, &weaponID)
+		if int(weaponID) < len(h.Weapons) {
+			damages[i].Weapon = h.Weapons[weaponID]
+		}
+		var dmg, dmgArmor, hpAfter int16
+		binary.Read(r, binary.BigEndian, &dmg)
+		binary.Read(r, binary.BigEndian, &dmgArmor)
+		damages[i].Damage, damages[i].DamageArmor = int(dmg), int(dmgArmor)
+		var err error
+		if damages[i].HitGroup, err = readString(r); err != nil {
+			return nil, err
+		}
+		binary.Read(r, binary.BigEndian, &hpAfter)
+		damages[i].VictimHPAfter = int(hpAfter)
+	}
+	return damages, nil
+}
+
+func readImpacts(r io.Reader, h Header) ([]Impact, error) {
+	var n uint16
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, err
+	}
+	impacts := make([]Impact, n)
+	for i := range impacts {
+		var shooter uint8
+		binary.Read(r, binary.BigEndian, &shooter)
+		impacts[i].ShooterRosterIndex = int(shooter)
+		var err error
+		if impacts[i].X, err = readQuantizedAxisPlain(r, h.RefX); err != nil {
+			return nil, err
+		}
+		if impacts[i].Y, err = readQuantizedAxisPlain(r, h.RefY); err != nil {
+			return nil, err
+		}
+		if impacts[i].Z, err = readQuantizedAxisPlain(r, h.RefZ); err != nil {
+			return nil, err
+		}
+	}
+	return impacts, nil
+}
+
+func readBomb(r io.Reader, h Header) (Bomb, error) {
+	var b Bomb
+	if err := binary.Read(r, binary.BigEndian, &b.IsPlanted); err != nil {
+		return b, err
+	}
+	var carrier uint8
+	binary.Read(r, binary.BigEndian, &carrier)
+	b.CarrierRosterIndex = int(carrier)
+	var err error
+	if b.X, err = readQuantizedAxisPlain(r, h.RefX); err != nil {
+		return b, err
+	}
+	if b.Y, err = readQuantizedAxisPlain(r, h.RefY); err != nil {
+		return b, err
+	}
+	if b.Z, err = readQuantizedAxisPlain(r, h.RefZ); err != nil {
+		return b, err
+	}
+	return b, nil
+}
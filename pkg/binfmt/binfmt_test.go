@@ -0,0 +1,213 @@
+package binfmt
+
+import (
+	"math"
+	"testing"
+)
+
+// There's no .dem fixture available in this environment, so this round-trips
+// a synthetic header/frame set instead of a real demo. It still exercises
+// every quantized field and both the full and delta record paths.
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	header := Header{
+		MapName:          "de_mirage",
+		TickRate:         16,
+		OriginalTickRate: 64,
+		RefX:             -2000,
+		RefY:             -1500,
+		RefZ:             0,
+		Weapons:          []string{"", "ak47", "m4a1", "hegrenade"},
+		Roster: []RosterEntry{
+			{SteamID64: 111, Name: "alice", RosterIndex: 1},
+			{SteamID64: 222, Name: "bob", RosterIndex: 6},
+		},
+	}
+
+	frames := []Frame{
+		{
+			Tick: 100,
+			Players: []Player{
+				{
+					RosterIndex: 1, Team: "CT", IsAlive: true,
+					X: -1800.5, Y: -1200.25, Z: 64, Rotation: 45.5,
+					Hp: 100, Armor: 100, Money: 4000, HasHelmet: true,
+					ActiveWeapon: "ak47", Kills: 1, Deaths: 0, Assists: 0, HS: 1,
+				},
+				{
+					RosterIndex: 6, Team: "T", IsAlive: true,
+					X: -1500, Y: -1400, Z: 64, Rotation: 180,
+					Hp: 80, Armor: 50, Money: 2000,
+					ActiveWeapon: "m4a1", Kills: 0, Deaths: 0, Assists: 0, HS: 0,
+				},
+			},
+		},
+		{
+			// Delta frame: only player 1's HP, position and flash state
+			// change; player 6 is unchanged and should be reconstructed
+			// from the cache.
+			Tick: 104,
+			Players: []Player{
+				{
+					RosterIndex: 1, Team: "CT", IsAlive: true,
+					X: -1790, Y: -1195, Z: 64, Rotation: 45.5,
+					Hp: 70, Armor: 100, Money: 4000, HasHelmet: true,
+					ActiveWeapon: "ak47", Kills: 1, Deaths: 0, Assists: 0, HS: 1,
+					IsFlashed: true, FlashMs: 800,
+				},
+				{
+					RosterIndex: 6, Team: "T", IsAlive: true,
+					X: -1500, Y: -1400, Z: 64, Rotation: 180,
+					Hp: 80, Armor: 50, Money: 2000,
+					ActiveWeapon: "m4a1", Kills: 0, Deaths: 0, Assists: 0, HS: 0,
+				},
+			},
+			Grenades: []Grenade{
+				{ID: 1, Type: "HE", X: -1700, Y: -1300, Z: 64, StartTick: 100, EndTick: 200},
+				{ID: 2, Type: "FLASH", X: -1600, Y: -1250, Z: 64, StartTick: 102, EndTick: 104, FlashedCT: 1, FlashedT: 2},
+			},
+			Projectiles: []Projectile{
+				{ID: 3, Type: "hegrenade", X: -1750, Y: -1310, Z: 70},
+			},
+			Fires: []Fire{
+				{RosterIndex: 1, Weapon: "ak47", Pitch: -5.5, Yaw: 90},
+				{RosterIndex: 6, Weapon: "m4a1", Pitch: 1, Yaw: -90, HasTracer: true, TracerEndX: -1500, TracerEndY: -1400, TracerEndZ: 64},
+			},
+			Damages: []Damage{
+				{AttackerRosterIndex: 1, VictimRosterIndex: 6, Weapon: "ak47", Damage: 30, DamageArmor: 5, HitGroup: "chest", VictimHPAfter: 70},
+			},
+			Impacts: []Impact{
+				{ShooterRosterIndex: 1, X: -1500, Y: -1400, Z: 64},
+			},
+			Bomb: Bomb{X: -1650, Y: -1280, Z: 64, IsPlanted: true, CarrierRosterIndex: 6},
+		},
+	}
+
+	encoded, err := Encode(header, frames)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	gotHeader, gotFrames, err := Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	if gotHeader.MapName != header.MapName {
+		t.Errorf("MapName = %q, want %q", gotHeader.MapName, header.MapName)
+	}
+	if len(gotHeader.Roster) != len(header.Roster) || len(gotHeader.Weapons) != len(header.Weapons) {
+		t.Fatalf("roster/weapon dictionary size mismatch: got %+v", gotHeader)
+	}
+	if len(gotFrames) != len(frames) {
+		t.Fatalf("got %d frames, want %d", len(gotFrames), len(frames))
+	}
+
+	for fi, wantFrame := range frames {
+		gotFrame := gotFrames[fi]
+		if gotFrame.Tick != wantFrame.Tick {
+			t.Errorf("frame %d: Tick = %d, want %d", fi, gotFrame.Tick, wantFrame.Tick)
+		}
+		if len(gotFrame.Players) != len(wantFrame.Players) {
+			t.Fatalf("frame %d: got %d players, want %d", fi, len(gotFrame.Players), len(wantFrame.Players))
+		}
+		for pi, want := range wantFrame.Players {
+			got := gotFrame.Players[pi]
+
+			if got.RosterIndex != want.RosterIndex {
+				t.Errorf("frame %d player %d: RosterIndex = %d, want %d", fi, pi, got.RosterIndex, want.RosterIndex)
+			}
+			if got.IsAlive != want.IsAlive || got.HasHelmet != want.HasHelmet || got.IsFlashed != want.IsFlashed {
+				t.Errorf("frame %d player %d: flags = %+v, want flags from %+v", fi, pi, got, want)
+			}
+			if got.Hp != want.Hp || got.Armor != want.Armor {
+				t.Errorf("frame %d player %d: Hp/Armor = %d/%d, want %d/%d", fi, pi, got.Hp, got.Armor, want.Hp, want.Armor)
+			}
+			if got.Money != want.Money {
+				t.Errorf("frame %d player %d: Money = %d, want %d", fi, pi, got.Money, want.Money)
+			}
+			if delta := math.Abs(float64(got.Rotation) - float64(want.Rotation)); delta > 360.0/(1<<RotationBits)+0.01 {
+				t.Errorf("frame %d player %d: Rotation = %v, want %v (delta %v exceeds quantization step)", fi, pi, got.Rotation, want.Rotation, delta)
+			}
+			if math.Abs(got.X-want.X) > PositionScale || math.Abs(got.Y-want.Y) > PositionScale || math.Abs(got.Z-want.Z) > PositionScale {
+				t.Errorf("frame %d player %d: position = (%v,%v,%v), want (%v,%v,%v) within %v", fi, pi, got.X, got.Y, got.Z, want.X, want.Y, want.Z, PositionScale)
+			}
+			if got.ActiveWeapon != want.ActiveWeapon {
+				t.Errorf("frame %d player %d: ActiveWeapon = %q, want %q", fi, pi, got.ActiveWeapon, want.ActiveWeapon)
+			}
+			if got.Kills != want.Kills || got.Deaths != want.Deaths || got.Assists != want.Assists || got.HS != want.HS {
+				t.Errorf("frame %d player %d: combat stats = %+v, want from %+v", fi, pi, got, want)
+			}
+			if got.FlashMs != want.FlashMs {
+				t.Errorf("frame %d player %d: FlashMs = %d, want %d", fi, pi, got.FlashMs, want.FlashMs)
+			}
+		}
+
+		if len(gotFrame.Grenades) != len(wantFrame.Grenades) {
+			t.Fatalf("frame %d: got %d grenades, want %d", fi, len(gotFrame.Grenades), len(wantFrame.Grenades))
+		}
+		for gi, want := range wantFrame.Grenades {
+			got := gotFrame.Grenades[gi]
+			if got.ID != want.ID || got.Type != want.Type || got.StartTick != want.StartTick || got.EndTick != want.EndTick {
+				t.Errorf("frame %d grenade %d: = %+v, want %+v", fi, gi, got, want)
+			}
+			if got.FlashedCT != want.FlashedCT || got.FlashedT != want.FlashedT {
+				t.Errorf("frame %d grenade %d: flashed counts = %d/%d, want %d/%d", fi, gi, got.FlashedCT, got.FlashedT, want.FlashedCT, want.FlashedT)
+			}
+			if math.Abs(got.X-want.X) > PositionScale || math.Abs(got.Y-want.Y) > PositionScale || math.Abs(got.Z-want.Z) > PositionScale {
+				t.Errorf("frame %d grenade %d: position = (%v,%v,%v), want (%v,%v,%v) within %v", fi, gi, got.X, got.Y, got.Z, want.X, want.Y, want.Z, PositionScale)
+			}
+		}
+
+		if len(gotFrame.Projectiles) != len(wantFrame.Projectiles) {
+			t.Fatalf("frame %d: got %d projectiles, want %d", fi, len(gotFrame.Projectiles), len(wantFrame.Projectiles))
+		}
+		for pji, want := range wantFrame.Projectiles {
+			got := gotFrame.Projectiles[pji]
+			if got.ID != want.ID || got.Type != want.Type {
+				t.Errorf("frame %d projectile %d: = %+v, want %+v", fi, pji, got, want)
+			}
+		}
+
+		if len(gotFrame.Fires) != len(wantFrame.Fires) {
+			t.Fatalf("frame %d: got %d fires, want %d", fi, len(gotFrame.Fires), len(wantFrame.Fires))
+		}
+		for fri, want := range wantFrame.Fires {
+			got := gotFrame.Fires[fri]
+			if got.RosterIndex != want.RosterIndex || got.Weapon != want.Weapon || got.HasTracer != want.HasTracer {
+				t.Errorf("frame %d fire %d: = %+v, want %+v", fi, fri, got, want)
+			}
+		}
+
+		if len(gotFrame.Damages) != len(wantFrame.Damages) {
+			t.Fatalf("frame %d: got %d damages, want %d", fi, len(gotFrame.Damages), len(wantFrame.Damages))
+		}
+		for di, want := range wantFrame.Damages {
+			got := gotFrame.Damages[di]
+			if got.AttackerRosterIndex != want.AttackerRosterIndex || got.VictimRosterIndex != want.VictimRosterIndex ||
+				got.Weapon != want.Weapon || got.Damage != want.Damage || got.DamageArmor != want.DamageArmor ||
+				got.HitGroup != want.HitGroup || got.VictimHPAfter != want.VictimHPAfter {
+				t.Errorf("frame %d damage %d: = %+v, want %+v", fi, di, got, want)
+			}
+		}
+
+		if len(gotFrame.Impacts) != len(wantFrame.Impacts) {
+			t.Fatalf("frame %d: got %d impacts, want %d", fi, len(gotFrame.Impacts), len(wantFrame.Impacts))
+		}
+		for ii, want := range wantFrame.Impacts {
+			got := gotFrame.Impacts[ii]
+			if got.ShooterRosterIndex != want.ShooterRosterIndex {
+				t.Errorf("frame %d impact %d: ShooterRosterIndex = %d, want %d", fi, ii, got.ShooterRosterIndex, want.ShooterRosterIndex)
+			}
+		}
+
+		if gotFrame.Bomb.IsPlanted != wantFrame.Bomb.IsPlanted || gotFrame.Bomb.CarrierRosterIndex != wantFrame.Bomb.CarrierRosterIndex {
+			t.Errorf("frame %d: Bomb = %+v, want %+v", fi, gotFrame.Bomb, wantFrame.Bomb)
+		}
+	}
+
+	// Sanity check that Encode produced a payload at all; its size is not
+	// compared against the equivalent JSON here.
+	if len(encoded) == 0 {
+		t.Fatal("Encode produced empty output")
+	}
+}
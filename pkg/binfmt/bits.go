@@ -0,0 +1,82 @@
+package binfmt
+
+// bitWriter packs values of arbitrary bit width (<=64) into a byte slice,
+// most-significant-bit-first within each value, appended left to right.
+type bitWriter struct {
+	buf    []byte
+	bitPos uint // number of bits already written into buf's last byte (0-7)
+}
+
+func (w *bitWriter) WriteBits(value uint64, nbits int) {
+	for nbits > 0 {
+		if w.bitPos == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		free := 8 - int(w.bitPos)
+		take := nbits
+		if take > free {
+			take = free
+		}
+
+		shift := nbits - take
+		chunk := byte((value >> uint(shift)) & ((1 << uint(take)) - 1))
+		w.buf[len(w.buf)-1] |= chunk << uint(free-take)
+
+		w.bitPos = (w.bitPos + uint(take)) % 8
+		nbits -= take
+	}
+}
+
+func (w *bitWriter) WriteBool(b bool) {
+	if b {
+		w.WriteBits(1, 1)
+	} else {
+		w.WriteBits(0, 1)
+	}
+}
+
+func (w *bitWriter) Bytes() []byte {
+	return w.buf
+}
+
+// bitReader is the mirror of bitWriter.
+type bitReader struct {
+	buf     []byte
+	bytePos int
+	bitPos  uint
+}
+
+func newBitReader(buf []byte) *bitReader {
+	return &bitReader{buf: buf}
+}
+
+func (r *bitReader) ReadBits(nbits int) uint64 {
+	var value uint64
+	for nbits > 0 {
+		if r.bytePos >= len(r.buf) {
+			return value << uint(nbits) // ran out of data; zero-pad
+		}
+		free := 8 - int(r.bitPos)
+		take := nbits
+		if take > free {
+			take = free
+		}
+
+		shift := free - take
+		mask := byte((1 << uint(take)) - 1)
+		chunk := (r.buf[r.bytePos] >> uint(shift)) & mask
+
+		value = (value << uint(take)) | uint64(chunk)
+
+		r.bitPos = (r.bitPos + uint(take)) % 8
+		if r.bitPos == 0 {
+			r.bytePos++
+		}
+		nbits -= take
+	}
+	return value
+}
+
+func (r *bitReader) ReadBool() bool {
+	return r.ReadBits(1) == 1
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/yerevin/cs2-2d-demoviewer/internal/stats"
+)
+
+// SeriesOptions controls ParseSeries.
+type SeriesOptions struct {
+	// SeriesID is an opaque identifier echoed back on SeriesData, e.g. a
+	// tournament match ID.
+	SeriesID string
+
+	// Team1Name/Team2Name label the two logical teams in the output. The
+	// assignment of which team is "1" vs "2" is otherwise arbitrary: it is
+	// fixed by whichever roster occupies CT on the first map.
+	Team1Name string
+	Team2Name string
+
+	IncludePositions bool
+	TickSkip         int
+}
+
+// SeriesPlayerAggregate is one player's stat line summed across every map
+// of the series.
+type SeriesPlayerAggregate struct {
+	SteamID64   uint64                  `json:"steam_id_64"`
+	Name        string                  `json:"name"`
+	RosterIndex int                     `json:"roster_index"`
+	Kills       int                     `json:"kills"`
+	Deaths      int                     `json:"deaths"`
+	Assists     int                     `json:"assists"`
+	HS          int                     `json:"hs"`
+	Stats       *stats.PlayerMatchStats `json:"stats,omitempty"`
+}
+
+// SeriesTeam is one side of the series: its per-map score, series score,
+// and aggregate player stats.
+type SeriesTeam struct {
+	ID          string                  `json:"id"`
+	Name        string                  `json:"name,omitempty"`
+	SeriesScore int                     `json:"series_score"`
+	MapScores   []int                   `json:"map_scores"`
+	Players     []SeriesPlayerAggregate `json:"players"`
+}
+
+// SeriesData is the result of ParseSeries: every map's MatchData plus
+// series-level team aggregates.
+type SeriesData struct {
+	SeriesID string      `json:"series_id,omitempty"`
+	NumMaps  int         `json:"num_maps"`
+	Team1    SeriesTeam  `json:"team1"`
+	Team2    SeriesTeam  `json:"team2"`
+	Maps     []MatchData `json:"maps"`
+}
+
+// ParseSeries runs ParseDemo (via parseDemoToMatchData) over each demo in
+// order and merges the results into a SeriesData. Player identity across
+// maps is by SteamID64: whichever roster a player belonged to on map 1 (CT
+// = team1, T = team2) is carried forward, since CT/T sides swap between
+// maps but team rosters don't.
+func ParseSeries(demos []io.Reader, opts SeriesOptions) (*SeriesData, error) {
+	if len(demos) == 0 {
+		return nil, fmt.Errorf("ParseSeries requires at least one demo")
+	}
+
+	exportOpts := ExportOptions{
+		Format:           FormatJSON,
+		IncludePositions: opts.IncludePositions,
+		TickSkip:         opts.TickSkip,
+	}
+	if exportOpts.TickSkip <= 0 {
+		exportOpts.TickSkip = 4
+	}
+
+	maps := make([]MatchData, 0, len(demos))
+	for i, r := range demos {
+		md, err := parseDemoToMatchData(r, exportOpts)
+		if err != nil {
+			return nil, fmt.Errorf("map %d: %w", i+1, err)
+		}
+		md.MapNumber = i + 1
+		maps = append(maps, md)
+	}
+
+	team1IDs, team2IDs := rosterSteamIDs(lastFrameOf(maps[0]))
+
+	// canonicalRosterIndex fixes every player's RosterIndex for the whole
+	// series at whatever map 1 assigned them (1-5 team1, 6-10 team2), since
+	// CT/T sides commonly swap between maps and a per-map RosterIndex would
+	// flip along with them. usedIndices tracks which slots are taken so a
+	// substitute who wasn't on map 1's roster still gets one consistent
+	// unused slot in their team's range across every later map.
+	canonicalRosterIndex := canonicalRosterIndices(lastFrameOf(maps[0]))
+	usedIndices := make(map[int]bool, len(canonicalRosterIndex))
+	for _, idx := range canonicalRosterIndex {
+		usedIndices[idx] = true
+	}
+	// nextFreeIndex assigns a newly-seen player the next unused slot in
+	// their team's 1-5/6-10 range, or reports ok=false if the side already
+	// has all 5 slots taken. RosterIndex is bit-packed into 4 bits by
+	// pkg/binfmt and is assumed by the viewer to mean "1-5 = team1, 6-10 =
+	// team2", so there is no spare slot a 6th distinct player could safely
+	// take: an earlier version of this function reused an already-assigned
+	// index in that case, which silently made two different SteamID64s
+	// render as the same dot.
+	nextFreeIndex := func(isTeam1 bool) (int, bool) {
+		lo, hi := 1, 5
+		if !isTeam1 {
+			lo, hi = 6, 10
+		}
+		for i := lo; i <= hi; i++ {
+			if !usedIndices[i] {
+				usedIndices[i] = true
+				return i, true
+			}
+		}
+		return 0, false
+	}
+
+	team1 := SeriesTeam{ID: "team1", Name: opts.Team1Name}
+	team2 := SeriesTeam{ID: "team2", Name: opts.Team2Name}
+	playerAgg := map[uint64]*SeriesPlayerAggregate{}
+
+	for mi := range maps {
+		md := maps[mi]
+		lastFrame := lastFrameOf(md)
+		ctIsTeam1 := sideMajorityIsTeam1(lastFrame, team1IDs, team2IDs)
+
+		team1Score, team2Score := md.TScore, md.CTScore
+		if ctIsTeam1 {
+			team1Score, team2Score = md.CTScore, md.TScore
+		}
+		team1.MapScores = append(team1.MapScores, team1Score)
+		team2.MapScores = append(team2.MapScores, team2Score)
+		if team1Score > team2Score {
+			team1.SeriesScore++
+		} else if team2Score > team1Score {
+			team2.SeriesScore++
+		}
+
+		for _, p := range lastFrame.Players {
+			if _, known := team1IDs[p.ID]; !known {
+				if _, known := team2IDs[p.ID]; !known {
+					// A player who wasn't on map 1's roster (e.g. a
+					// substitute): classify by which side they're playing
+					// relative to which side we determined is team1.
+					if (p.Team == "CT") == ctIsTeam1 {
+						team1IDs[p.ID] = true
+					} else {
+						team2IDs[p.ID] = true
+					}
+				}
+			}
+			if _, known := canonicalRosterIndex[p.ID]; !known {
+				idx, ok := nextFreeIndex(team1IDs[p.ID])
+				if !ok {
+					side := "team2"
+					if team1IDs[p.ID] {
+						side = "team1"
+					}
+					return nil, fmt.Errorf("map %d: %s has more than 5 distinct players across the series (SteamID64 %d has no free RosterIndex slot)", mi+1, side, p.ID)
+				}
+				canonicalRosterIndex[p.ID] = idx
+			}
+		}
+
+		// Rewrite every frame's RosterIndex to the series-wide canonical
+		// value. md.Frames is a slice, so mutating it here also updates the
+		// MatchData already stored in maps[mi] since both share the same
+		// backing array.
+		for fi := range md.Frames {
+			for pi := range md.Frames[fi].Players {
+				id := md.Frames[fi].Players[pi].ID
+				if idx, ok := canonicalRosterIndex[id]; ok {
+					md.Frames[fi].Players[pi].RosterIndex = idx
+				}
+			}
+		}
+
+		for _, p := range lastFrame.Players {
+			agg, ok := playerAgg[p.ID]
+			if !ok {
+				agg = &SeriesPlayerAggregate{SteamID64: p.ID, Name: p.Name, RosterIndex: canonicalRosterIndex[p.ID]}
+				playerAgg[p.ID] = agg
+			}
+			agg.Kills += p.Kills
+			agg.Deaths += p.Deaths
+			agg.Assists += p.Assists
+			agg.HS += p.HS
+			if p.Stats != nil {
+				if agg.Stats == nil {
+					agg.Stats = &stats.PlayerMatchStats{}
+				}
+				stats.MergeInto(agg.Stats, p.Stats)
+			}
+		}
+	}
+
+	for id, agg := range playerAgg {
+		if team1IDs[id] {
+			team1.Players = append(team1.Players, *agg)
+		} else {
+			team2.Players = append(team2.Players, *agg)
+		}
+	}
+	sort.Slice(team1.Players, func(i, j int) bool { return team1.Players[i].RosterIndex < team1.Players[j].RosterIndex })
+	sort.Slice(team2.Players, func(i, j int) bool { return team2.Players[i].RosterIndex < team2.Players[j].RosterIndex })
+
+	return &SeriesData{
+		SeriesID: opts.SeriesID,
+		NumMaps:  len(maps),
+		Team1:    team1,
+		Team2:    team2,
+		Maps:     maps,
+	}, nil
+}
+
+// lastFrameOf returns a map's final sampled frame, which holds every
+// player's final cumulative per-map stats and roster assignment.
+func lastFrameOf(md MatchData) FrameData {
+	if len(md.Frames) == 0 {
+		return FrameData{}
+	}
+	return md.Frames[len(md.Frames)-1]
+}
+
+// rosterSteamIDs splits a frame's players into team1 (RosterIndex 1-5, CT
+// at match start) and team2 (RosterIndex 6-10, T at match start).
+func rosterSteamIDs(lf FrameData) (team1, team2 map[uint64]bool) {
+	team1, team2 = map[uint64]bool{}, map[uint64]bool{}
+	for _, p := range lf.Players {
+		switch {
+		case p.RosterIndex >= 1 && p.RosterIndex <= 5:
+			team1[p.ID] = true
+		case p.RosterIndex >= 6 && p.RosterIndex <= 10:
+			team2[p.ID] = true
+		}
+	}
+	return team1, team2
+}
+
+// canonicalRosterIndices returns the series-wide fixed RosterIndex for every
+// player present on a frame, keyed by SteamID64. It is seeded from map 1's
+// frame, whose RosterIndex assignment is treated as canonical for the whole
+// series (see ParseSeries).
+func canonicalRosterIndices(lf FrameData) map[uint64]int {
+	idx := make(map[uint64]int, len(lf.Players))
+	for _, p := range lf.Players {
+		idx[p.ID] = p.RosterIndex
+	}
+	return idx
+}
+
+// sideMajorityIsTeam1 reports whether team1's roster makes up the majority
+// of this map's CT side, i.e. whether CTScore/TScore on this map should be
+// read as team1Score/team2Score or the other way around.
+func sideMajorityIsTeam1(lf FrameData, team1IDs, team2IDs map[uint64]bool) bool {
+	var ctTeam1, ctTeam2 int
+	for _, p := range lf.Players {
+		if p.Team != "CT" {
+			continue
+		}
+		if team1IDs[p.ID] {
+			ctTeam1++
+		} else if team2IDs[p.ID] {
+			ctTeam2++
+		}
+	}
+	return ctTeam1 >= ctTeam2
+}
@@ -0,0 +1,77 @@
+package main
+
+import "testing"
+
+func TestLastFrameOf(t *testing.T) {
+	md := MatchData{Frames: []FrameData{
+		{Tick: 1, Players: []PlayerData{{ID: 1}}},
+		{Tick: 2, Players: []PlayerData{{ID: 1}, {ID: 2}}},
+	}}
+	lf := lastFrameOf(md)
+	if lf.Tick != 2 || len(lf.Players) != 2 {
+		t.Errorf("lastFrameOf = %+v, want the final frame", lf)
+	}
+
+	if lf := lastFrameOf(MatchData{}); lf.Tick != 0 || lf.Players != nil {
+		t.Errorf("lastFrameOf of an empty MatchData = %+v, want zero value", lf)
+	}
+}
+
+func TestRosterSteamIDs(t *testing.T) {
+	lf := FrameData{Players: []PlayerData{
+		{ID: 1, RosterIndex: 1},
+		{ID: 2, RosterIndex: 5},
+		{ID: 3, RosterIndex: 6},
+		{ID: 4, RosterIndex: 10},
+		{ID: 5, RosterIndex: 0}, // unassigned, e.g. a spectator
+	}}
+
+	team1, team2 := rosterSteamIDs(lf)
+	if !team1[1] || !team1[2] {
+		t.Errorf("team1 = %v, want {1, 2}", team1)
+	}
+	if !team2[3] || !team2[4] {
+		t.Errorf("team2 = %v, want {3, 4}", team2)
+	}
+	if team1[5] || team2[5] {
+		t.Errorf("player 5 with RosterIndex 0 should be in neither team, got team1=%v team2=%v", team1[5], team2[5])
+	}
+}
+
+func TestCanonicalRosterIndices(t *testing.T) {
+	lf := FrameData{Players: []PlayerData{
+		{ID: 100, RosterIndex: 1},
+		{ID: 200, RosterIndex: 6},
+	}}
+	idx := canonicalRosterIndices(lf)
+	if idx[100] != 1 || idx[200] != 6 {
+		t.Errorf("canonicalRosterIndices = %v, want {100:1, 200:6}", idx)
+	}
+}
+
+func TestSideMajorityIsTeam1(t *testing.T) {
+	team1IDs := map[uint64]bool{1: true, 2: true}
+	team2IDs := map[uint64]bool{3: true, 4: true}
+
+	// team1's roster (1, 2) is currently on CT.
+	lf := FrameData{Players: []PlayerData{
+		{ID: 1, Team: "CT"},
+		{ID: 2, Team: "CT"},
+		{ID: 3, Team: "T"},
+		{ID: 4, Team: "T"},
+	}}
+	if !sideMajorityIsTeam1(lf, team1IDs, team2IDs) {
+		t.Error("sideMajorityIsTeam1 = false, want true (team1's roster is on CT)")
+	}
+
+	// Sides swap: team2's roster is now on CT.
+	lf = FrameData{Players: []PlayerData{
+		{ID: 3, Team: "CT"},
+		{ID: 4, Team: "CT"},
+		{ID: 1, Team: "T"},
+		{ID: 2, Team: "T"},
+	}}
+	if sideMajorityIsTeam1(lf, team1IDs, team2IDs) {
+		t.Error("sideMajorityIsTeam1 = true, want false (team2's roster is on CT)")
+	}
+}
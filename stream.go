@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/yerevin/cs2-2d-demoviewer/internal/stats"
+)
+
+// StreamOptions controls ParseDemoStream. Unlike ExportOptions it has no
+// Format field: streaming only makes sense as NDJSON, one JSON object per
+// line.
+type StreamOptions struct {
+	// IncludePositions controls whether per-frame X/Y/Z is emitted for
+	// players, projectiles and grenades, same as ExportOptions.
+	IncludePositions bool
+
+	// TickSkip is the frame sampling interval. Defaults to 4 when zero.
+	TickSkip int
+}
+
+// streamMatchHeader is written by ParseDemoStream just before
+// streamMatchFooter, once the map name and tick rate are actually known.
+type streamMatchHeader struct {
+	Type             string  `json:"type"`
+	MapName          string  `json:"map_name"`
+	TickRate         float64 `json:"tick_rate"`
+	OriginalTickRate float64 `json:"original_tick_rate"`
+}
+
+// streamMatchFooter is the last line written by ParseDemoStream. PlayerStats
+// carries each player's final match stat line, keyed by SteamID64: since
+// streaming never knows which frame will turn out to be the last one, this
+// is the only place that value is ever reported (FrameData.Players[].Stats
+// is always nil over the wire).
+type streamMatchFooter struct {
+	Type           string                             `json:"type"`
+	CTScore        int                                `json:"ct_score"`
+	TScore         int                                `json:"t_score"`
+	MatchStartTick int                                `json:"match_start_tick"`
+	NumRounds      int                                `json:"num_rounds"`
+	NumKills       int                                `json:"num_kills"`
+	PlayerStats    map[uint64]*stats.PlayerMatchStats `json:"player_stats,omitempty"`
+}
+
+type streamFrame struct {
+	Type string `json:"type"`
+	FrameData
+}
+
+type streamRound struct {
+	Type string `json:"type"`
+	RoundData
+}
+
+type streamKill struct {
+	Type string `json:"type"`
+	KillEvent
+}
+
+type streamGrenadeStart struct {
+	Type string `json:"type"`
+	GrenadeEffect
+}
+
+type streamGrenadeEnd struct {
+	Type string `json:"type"`
+	GrenadeEffect
+}
+
+func writeNDJSONLine(w io.Writer, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	_, err = w.Write(b)
+	return err
+}
+
+// ParseDemoStream parses r and writes one NDJSON line per event to w:
+// frame/round/kill/grenade_start/grenade_end lines interleaved in the order
+// they occur in the demo, a match_header, and a trailing match_footer.
+// match_header is written right before match_footer rather than as the
+// first line: the map name and tick rate it reports aren't known until
+// ParseToEnd has consumed the demo's file header, so writing it eagerly
+// (as an earlier version of this function did) reported an empty map name
+// and a hardcoded tick rate. Unlike ParseDemoWithOptions this never
+// accumulates the full set of frames, kills or grenade effects in memory,
+// so it is safe to use on a full-length demo from a memory-constrained
+// environment such as WASM.
+func ParseDemoStream(r io.Reader, w io.Writer, opts StreamOptions) error {
+	if opts.TickSkip <= 0 {
+		opts.TickSkip = 4
+	}
+
+	var firstErr error
+	emit := func(v any) {
+		if firstErr != nil {
+			return
+		}
+		firstErr = writeNDJSONLine(w, v)
+	}
+
+	numRounds := 0
+	numKills := 0
+
+	result, err := runPipeline(r, pipelineOptions{
+		IncludePositions: opts.IncludePositions,
+		TickSkip:         opts.TickSkip,
+	}, pipelineCallbacks{
+		onFrame: func(fd FrameData) {
+			emit(streamFrame{Type: "frame", FrameData: fd})
+		},
+		onRound: func(rd RoundData) {
+			emit(streamRound{Type: "round", RoundData: rd})
+			numRounds++
+		},
+		onKill: func(ke KillEvent) {
+			emit(streamKill{Type: "kill", KillEvent: ke})
+			numKills++
+		},
+		onGrenadeStart: func(eff GrenadeEffect) {
+			emit(streamGrenadeStart{Type: "grenade_start", GrenadeEffect: eff})
+		},
+		onGrenadeEnd: func(eff GrenadeEffect) {
+			emit(streamGrenadeEnd{Type: "grenade_end", GrenadeEffect: eff})
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+
+	if err := writeNDJSONLine(w, streamMatchHeader{
+		Type:             "match_header",
+		MapName:          result.MapName,
+		TickRate:         result.TickRate / float64(opts.TickSkip),
+		OriginalTickRate: result.TickRate,
+	}); err != nil {
+		return err
+	}
+
+	return writeNDJSONLine(w, streamMatchFooter{
+		Type:           "match_footer",
+		CTScore:        result.CTScore,
+		TScore:         result.TScore,
+		MatchStartTick: result.MatchStartTick,
+		NumRounds:      numRounds,
+		NumKills:       numKills,
+		PlayerStats:    result.PlayerStats,
+	})
+}
@@ -4,9 +4,55 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
 	"syscall/js"
 )
 
+// jsLineWriter adapts a JS callback to io.Writer. ParseDemoStream always
+// writes one complete NDJSON line (including the trailing newline) per
+// Write call, so each call maps to exactly one onLine invocation.
+type jsLineWriter struct {
+	onLine js.Value
+}
+
+func (w jsLineWriter) Write(p []byte) (int, error) {
+	w.onLine.Invoke(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+// exportOptionsFromJS reads an optional second argument of the shape
+// {format: "json"|"minified-json"|"binary", includePositions: bool, tickSkip: int}
+// into an ExportOptions, defaulting to DefaultExportOptions() when the
+// argument is omitted. FormatCSV is not supported here since CSV export
+// writes multiple files, which doesn't fit this single-return-value API.
+func exportOptionsFromJS(args []js.Value) ExportOptions {
+	opts := DefaultExportOptions()
+	if len(args) < 2 || args[1].IsUndefined() || args[1].IsNull() {
+		return opts
+	}
+
+	jsOpts := args[1]
+	if format := jsOpts.Get("format"); !format.IsUndefined() {
+		switch format.String() {
+		case "minified-json":
+			opts.Format = FormatMinifiedJSON
+		case "binary":
+			opts.Format = FormatBinary
+		default:
+			opts.Format = FormatJSON
+		}
+	}
+	if includePositions := jsOpts.Get("includePositions"); !includePositions.IsUndefined() {
+		opts.IncludePositions = includePositions.Bool()
+	}
+	if tickSkip := jsOpts.Get("tickSkip"); !tickSkip.IsUndefined() {
+		opts.TickSkip = tickSkip.Int()
+	}
+	return opts
+}
+
 func main() {
 	js.Global().Set("parseDemoWasm", js.FuncOf(func(this js.Value, args []js.Value) any {
 		if len(args) == 0 {
@@ -16,12 +62,98 @@ func main() {
 		demoBytes := make([]byte, args[0].Length())
 		js.CopyBytesToGo(demoBytes, args[0])
 
-		jsonData, err := ParseDemo(bytes.NewReader(demoBytes))
+		opts := exportOptionsFromJS(args)
+		data, err := ParseDemoWithOptions(bytes.NewReader(demoBytes), opts)
 		if err != nil {
 			return map[string]any{"error": err.Error()}
 		}
 
-		return string(jsonData)
+		// Binary output is not valid UTF-8, so it goes back as a Uint8Array
+		// rather than a JS string.
+		if opts.Format == FormatBinary {
+			arr := js.Global().Get("Uint8Array").New(len(data))
+			js.CopyBytesToJS(arr, data)
+			return arr
+		}
+		return string(data)
+	}))
+
+	// parseDemoWasmStream(bytes, onLine, opts?) streams NDJSON lines to the
+	// onLine callback as the demo is parsed, so a browser viewer can start
+	// rendering round 1 while later rounds are still being processed,
+	// instead of waiting for the whole match to parse first.
+	js.Global().Set("parseDemoWasmStream", js.FuncOf(func(this js.Value, args []js.Value) any {
+		if len(args) < 2 {
+			return map[string]any{"error": "missing demo bytes or onLine callback"}
+		}
+
+		demoBytes := make([]byte, args[0].Length())
+		js.CopyBytesToGo(demoBytes, args[0])
+		onLine := args[1]
+
+		streamOpts := StreamOptions{IncludePositions: true, TickSkip: 4}
+		if len(args) > 2 && !args[2].IsUndefined() && !args[2].IsNull() {
+			jsOpts := args[2]
+			if includePositions := jsOpts.Get("includePositions"); !includePositions.IsUndefined() {
+				streamOpts.IncludePositions = includePositions.Bool()
+			}
+			if tickSkip := jsOpts.Get("tickSkip"); !tickSkip.IsUndefined() {
+				streamOpts.TickSkip = tickSkip.Int()
+			}
+		}
+
+		err := ParseDemoStream(bytes.NewReader(demoBytes), jsLineWriter{onLine: onLine}, streamOpts)
+		if err != nil {
+			return map[string]any{"error": err.Error()}
+		}
+		return nil
+	}))
+
+	// parseSeriesWasm(arrayOfBytes, opts?) parses a BO3/BO5 series from an
+	// array of per-map demo byte arrays and returns the SeriesData as JSON.
+	js.Global().Set("parseSeriesWasm", js.FuncOf(func(this js.Value, args []js.Value) any {
+		if len(args) == 0 {
+			return map[string]any{"error": "missing array of demo bytes"}
+		}
+
+		demoArray := args[0]
+		readers := make([]io.Reader, demoArray.Length())
+		for i := range readers {
+			demoBytes := make([]byte, demoArray.Index(i).Length())
+			js.CopyBytesToGo(demoBytes, demoArray.Index(i))
+			readers[i] = bytes.NewReader(demoBytes)
+		}
+
+		seriesOpts := SeriesOptions{IncludePositions: true, TickSkip: 4}
+		if len(args) > 1 && !args[1].IsUndefined() && !args[1].IsNull() {
+			jsOpts := args[1]
+			if seriesID := jsOpts.Get("seriesId"); !seriesID.IsUndefined() {
+				seriesOpts.SeriesID = seriesID.String()
+			}
+			if team1Name := jsOpts.Get("team1Name"); !team1Name.IsUndefined() {
+				seriesOpts.Team1Name = team1Name.String()
+			}
+			if team2Name := jsOpts.Get("team2Name"); !team2Name.IsUndefined() {
+				seriesOpts.Team2Name = team2Name.String()
+			}
+			if includePositions := jsOpts.Get("includePositions"); !includePositions.IsUndefined() {
+				seriesOpts.IncludePositions = includePositions.Bool()
+			}
+			if tickSkip := jsOpts.Get("tickSkip"); !tickSkip.IsUndefined() {
+				seriesOpts.TickSkip = tickSkip.Int()
+			}
+		}
+
+		seriesData, err := ParseSeries(readers, seriesOpts)
+		if err != nil {
+			return map[string]any{"error": err.Error()}
+		}
+
+		data, err := json.Marshal(seriesData)
+		if err != nil {
+			return map[string]any{"error": err.Error()}
+		}
+		return string(data)
 	}))
 
 	select {}